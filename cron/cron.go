@@ -1,13 +1,20 @@
 package cron
 
+import "context"
+
 type Cron interface {
-	StartCronTask()
+	// StartCronTask 开始定时触发,任务使用 ctx 执行,ctx 被取消时执行中的任务也会被取消
+	StartCronTask(ctx context.Context)
+	// StopCronTask 停止定时触发,不会等待执行中的任务
+	StopCronTask()
 }
 
-// autoService服务还需要进行一个对表格的清理,如果学号已经超过毕业时间2年应当被自动清理
-
 func NewCron(
 	elecpriceController *ElecpriceController,
+	retentionController *RetentionController,
+	deliveryController *DeliveryController,
+	reportController *ReportController,
+	samplerController *SamplerController,
 ) []Cron {
-	return []Cron{elecpriceController}
+	return []Cron{elecpriceController, retentionController, deliveryController, reportController, samplerController}
 }
@@ -0,0 +1,134 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/service"
+	"github.com/spf13/viper"
+	"sync"
+	"time"
+)
+
+// DeliveryJobName 发送推迟提醒任务的名称
+const DeliveryJobName = "deferred_delivery"
+
+// DeliveryController 定期发送因免打扰或推送时间被推迟、已经到期的提醒
+type DeliveryController struct {
+	notificationService service.NotificationService
+	alertHistoryService service.AlertHistoryService
+	jobService          service.JobService
+	stopChan            chan struct{}
+	stopOnce            sync.Once
+	cfg                 DeliveryControllerConfig
+	l                   logger.Logger
+}
+
+type DeliveryControllerConfig struct {
+	IntervalMinutes int64 `yaml:"intervalMinutes"` // 检查周期,单位分钟
+	BatchSize       int   `yaml:"batchSize"`       // 每批读取的提醒数
+}
+
+func NewDeliveryController(
+	notificationService service.NotificationService,
+	alertHistoryService service.AlertHistoryService,
+	jobService service.JobService,
+	l logger.Logger,
+) *DeliveryController {
+	cfg := DeliveryControllerConfig{
+		IntervalMinutes: 5,
+		BatchSize:       100,
+	}
+	if err := viper.UnmarshalKey("deliveryController", &cfg); err != nil {
+		panic(err)
+	}
+	c := &DeliveryController{
+		notificationService: notificationService,
+		alertHistoryService: alertHistoryService,
+		jobService:          jobService,
+		stopChan:            make(chan struct{}),
+		cfg:                 cfg,
+		l:                   l,
+	}
+	jobService.RegisterJob(c)
+	return c
+}
+
+func (r *DeliveryController) StartCronTask(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(r.cfg.IntervalMinutes) * time.Minute)
+		for {
+			select {
+			case <-ticker.C:
+				_, err := r.jobService.RunJob(ctx, DeliveryJobName, false)
+				if err != nil {
+					r.l.Error("发送推迟的提醒失败!:", logger.FormatLog("cron", err)...)
+				}
+
+			case <-r.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (r *DeliveryController) StopCronTask() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
+
+func (r *DeliveryController) Name() string {
+	return DeliveryJobName
+}
+
+func (r *DeliveryController) Run(ctx context.Context, dryRun bool) (domain.JobResult, error) {
+	var (
+		res  domain.JobResult
+		errs []error
+	)
+
+	// 试运行只读取一批,已处理的提醒会改变状态,所以不需要游标
+	for {
+		alerts, err := r.notificationService.FindDueAlerts(ctx, r.cfg.BatchSize)
+		if err != nil {
+			return res, errors.Join(append(errs, err)...)
+		}
+
+		for _, alert := range alerts {
+			res.Checked++
+			if dryRun {
+				res.Events = append(res.Events, alert.Event)
+				res.Sent++
+				continue
+			}
+
+			channel, resp, err := r.notificationService.Send(ctx, alert.Event)
+			status := domain.AlertStatusSent
+			if err != nil {
+				res.Failed++
+				errs = append(errs, err)
+				status, resp = domain.AlertStatusFailed, err.Error()
+			} else {
+				res.Sent++
+			}
+			if alert.HistoryID != 0 {
+				if err := r.alertHistoryService.UpdateStatus(ctx, alert.HistoryID, channel, status, resp); err != nil {
+					r.l.Warn("更新提醒历史失败", logger.Error(err), logger.Int64("historyId", alert.HistoryID))
+				}
+			}
+			// 发送失败的提醒不再重试,避免同一条提醒反复打扰
+			if err := r.notificationService.MarkDelivered(ctx, alert.ID, err == nil); err != nil {
+				return res, errors.Join(append(errs, err)...)
+			}
+		}
+
+		if dryRun || len(alerts) < r.cfg.BatchSize {
+			break
+		}
+	}
+
+	return res, errors.Join(errs...)
+}
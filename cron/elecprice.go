@@ -2,52 +2,73 @@ package cron
 
 import (
 	"context"
-	"fmt"
-	feedv1 "github.com/asynccnu/be-api/gen/proto/feed/v1"
+	"errors"
+	"github.com/asynccnu/be-elecprice/domain"
 	"github.com/asynccnu/be-elecprice/pkg/logger"
 	"github.com/asynccnu/be-elecprice/service"
 	"github.com/spf13/viper"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// ElecpriceJobName 电费提醒任务的名称
+const ElecpriceJobName = "elecprice_alert"
+
 type ElecpriceController struct {
-	feedClient      feedv1.FeedServiceClient
-	elecpriceSerice service.ElecpriceService
-	stopChan        chan struct{}
-	cfg             ElecpriceControllerConfig
-	l               logger.Logger
+	elecpriceSerice     service.ElecpriceService
+	notificationService service.NotificationService
+	alertHistoryService service.AlertHistoryService
+	templateService     service.TemplateService
+	jobService          service.JobService
+	stopChan            chan struct{}
+	stopOnce            sync.Once
+	cfg                 ElecpriceControllerConfig
+	l                   logger.Logger
 }
 
 type ElecpriceControllerConfig struct {
 	DurationTime int64 `yaml:"durationTime"`
+	DryRun       bool  `yaml:"dryRun"` // 定时任务只记录将要发出的提醒,不真正发送
 }
 
 func NewElecpriceController(
-	feedClient feedv1.FeedServiceClient,
 	elecpriceSerice service.ElecpriceService,
+	notificationService service.NotificationService,
+	alertHistoryService service.AlertHistoryService,
+	templateService service.TemplateService,
+	jobService service.JobService,
 	l logger.Logger,
 ) *ElecpriceController {
 	var cfg ElecpriceControllerConfig
 	if err := viper.UnmarshalKey("elecpriceController", &cfg); err != nil {
 		panic(err)
 	}
-	return &ElecpriceController{
-		feedClient:      feedClient,
-		elecpriceSerice: elecpriceSerice,
-		stopChan:        make(chan struct{}),
-		cfg:             cfg,
-		l:               l,
+	c := &ElecpriceController{
+		elecpriceSerice:     elecpriceSerice,
+		notificationService: notificationService,
+		alertHistoryService: alertHistoryService,
+		templateService:     templateService,
+		jobService:          jobService,
+		stopChan:            make(chan struct{}),
+		cfg:                 cfg,
+		l:                   l,
 	}
+	// 注册后可以通过 TriggerJob 手动触发
+	jobService.RegisterJob(c)
+	return c
 }
 
-func (r *ElecpriceController) StartCronTask() {
+func (r *ElecpriceController) StartCronTask(ctx context.Context) {
 	go func() {
 		ticker := time.NewTicker(time.Duration(r.cfg.DurationTime) * time.Hour)
 		for {
 			select {
 			case <-ticker.C:
-				err := r.publishMSG()
-				r.l.Error("推送消息失败!:", logger.FormatLog("cron", err)...)
+				_, err := r.jobService.RunJob(ctx, ElecpriceJobName, r.cfg.DryRun)
+				if err != nil {
+					r.l.Error("推送消息失败!:", logger.FormatLog("cron", err)...)
+				}
 
 			case <-r.stopChan:
 				ticker.Stop()
@@ -58,29 +79,170 @@ func (r *ElecpriceController) StartCronTask() {
 
 }
 
-func (r *ElecpriceController) publishMSG() error {
-	//由于没有使用注册为路由这里手动写的上下文,每次提前四天进行提醒
+func (r *ElecpriceController) StopCronTask() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
 
-	ctx := context.Background()
-	msgs, err := r.elecpriceSerice.GetTobePushMSG(ctx)
+func (r *ElecpriceController) Name() string {
+	return ElecpriceJobName
+}
+
+func (r *ElecpriceController) Run(ctx context.Context, dryRun bool) (domain.JobResult, error) {
+	return r.publishMSG(ctx, dryRun)
+}
+
+func (r *ElecpriceController) publishMSG(ctx context.Context, dryRun bool) (domain.JobResult, error) {
+	//每次提前四天进行提醒
+	var res domain.JobResult
+
+	batch, err := r.elecpriceSerice.GetTobePushMSG(ctx)
 	if err != nil {
-		return err
+		return res, err
 	}
-	for i := range msgs {
-		if msgs[i].Remain != nil {
-
-			//发送给全体成员
-			_, err = r.feedClient.PublicFeedEvent(ctx, &feedv1.PublicFeedEventReq{
-				StudentId: msgs[i].StudentId,
-				Event: &feedv1.FeedEvent{
-					Type:    "energy",
-					Title:   "电费不足提醒",
-					Content: fmt.Sprintf("您的房间%s当前的电费为:%s,低于设置阈值,请及时充费", *(msgs[i].RoomName), *(msgs[i].Remain)),
-				},
-			})
+	res.Checked = batch.CheckedRooms
+	res.Failed = int64(len(batch.Errs))
+	errs := batch.Errs
+	// 成功发出提醒的阈值,用于冷却
+	var alerted []int64
+	// 成功发出用电异常提醒的订阅
+	var anomalies []int64
+	// 发出提醒的群组下一次轮流提醒的成员序号
+	rotations := make(map[int64]int64)
+	now := time.Now()
+
+	for i := range batch.MSGs {
+		if batch.MSGs[i].Remain == nil {
+			continue
+		}
+		event := r.newFeedEvent(ctx, batch.MSGs[i])
+
+		// 免打扰时段内或设置了推送时间的提醒推迟发送,已经欠费的提醒立即发送
+		remain, _ := strconv.ParseFloat(*batch.MSGs[i].Remain, 64)
+		at, err := r.notificationService.DeliverAt(ctx, event.StudentId, remain < 0, now)
+		if err != nil {
+			// 获取不到偏好时按原来的方式立即发送
+			r.l.Warn("获取提醒偏好失败", logger.Error(err), logger.String("studentId", event.StudentId))
+		} else if at.After(now) {
+			if !dryRun {
+				historyID := r.recordAlert(ctx, batch.MSGs[i], event, "", domain.AlertStatusDeferred, "")
+				err := r.notificationService.Defer(ctx, &domain.DeferredAlert{
+					Event:     event,
+					Kind:      deferredKind(batch.MSGs[i]),
+					DeliverAt: at.Unix(),
+					HistoryID: historyID,
+				})
+				if err != nil {
+					res.Failed++
+					errs = append(errs, err)
+					continue
+				}
+			}
+			res.Deferred++
+			// 推迟的提醒在保存时即进入冷却,避免下一次检查重复保存
+			if !dryRun {
+				alerted, anomalies = markSent(batch.MSGs[i], alerted, anomalies, rotations)
+			} else {
+				event.DeliverAt = at.Unix()
+				res.Events = append(res.Events, event)
+			}
+			continue
+		}
+
+		// 试运行只记录不发送
+		if dryRun {
+			r.l.Info("试运行,跳过发送提醒",
+				logger.String("studentId", event.StudentId),
+				logger.String("title", event.Title),
+				logger.String("content", event.Content),
+			)
+			res.Events = append(res.Events, event)
+			res.Sent++
+			continue
 		}
 
+		// 通过学生选择的渠道发送
+		channel, resp, err := r.notificationService.Send(ctx, event)
+		if err != nil {
+			r.recordAlert(ctx, batch.MSGs[i], event, channel, domain.AlertStatusFailed, err.Error())
+			res.Failed++
+			errs = append(errs, err)
+			continue
+		}
+		r.recordAlert(ctx, batch.MSGs[i], event, channel, domain.AlertStatusSent, resp)
+		res.Sent++
+		alerted, anomalies = markSent(batch.MSGs[i], alerted, anomalies, rotations)
+	}
+
+	if err := r.elecpriceSerice.MarkAlerted(ctx, alerted, rotations); err != nil {
+		errs = append(errs, err)
+	}
+	if err := r.elecpriceSerice.MarkAnomalyAlerted(ctx, anomalies); err != nil {
+		errs = append(errs, err)
 	}
 
-	return err
+	return res, errors.Join(errs...)
+}
+
+// markSent 按提醒的类型记录发出提醒的阈值或者订阅,群组的提醒同时记录下一次轮流提醒的成员
+func markSent(msg *domain.ElectricMSG, alerted []int64, anomalies []int64, rotations map[int64]int64) ([]int64, []int64) {
+	if msg.Anomaly != nil {
+		return alerted, append(anomalies, msg.SubscriptionID)
+	}
+	if msg.ThresholdID != 0 {
+		alerted = append(alerted, msg.ThresholdID)
+	}
+	if msg.GroupId != 0 {
+		rotations[msg.GroupId] = msg.NextRotate
+	}
+	return alerted, anomalies
+}
+
+// deferredKind 推迟的提醒的种类,用电异常和余额提醒互不替换
+func deferredKind(msg *domain.ElectricMSG) string {
+	if msg.Anomaly != nil {
+		return domain.DeferredKindAnomaly
+	}
+	return domain.DeferredKindThreshold
+}
+
+// recordAlert 保存提醒历史,返回记录的 ID,保存失败只记录日志不影响发送
+func (r *ElecpriceController) recordAlert(ctx context.Context, msg *domain.ElectricMSG, event *domain.FeedEvent, channel string, status string, feedResponse string) int64 {
+	record := &domain.AlertRecord{
+		StudentId:    event.StudentId,
+		RoomId:       msg.RoomId,
+		RoomName:     *(msg.RoomName),
+		Remain:       *(msg.Remain),
+		Limit:        msg.Limit,
+		Severity:     msg.Severity,
+		Title:        event.Title,
+		Content:      event.Content,
+		Status:       status,
+		Channel:      channel,
+		FeedResponse: feedResponse,
+	}
+	if status != domain.AlertStatusDeferred {
+		record.DeliveredAt = time.Now().Unix()
+	}
+	if err := r.alertHistoryService.Record(ctx, record); err != nil {
+		r.l.Warn("保存提醒历史失败", logger.Error(err), logger.String("studentId", event.StudentId))
+		return 0
+	}
+	return record.ID
+}
+
+func (r *ElecpriceController) newFeedEvent(ctx context.Context, msg *domain.ElectricMSG) *domain.FeedEvent {
+	title, content := r.templateService.RenderAlert(ctx, msg)
+	eventType := domain.FeedTypeEnergy
+	if msg.Anomaly != nil {
+		eventType = domain.FeedTypeEnergyAnomaly
+	}
+	return &domain.FeedEvent{
+		StudentId: msg.StudentId,
+		RoomId:    msg.RoomId,
+		Type:      eventType,
+		Title:     title,
+		Content:   content,
+	}
 }
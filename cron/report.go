@@ -0,0 +1,178 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/service"
+	"github.com/spf13/viper"
+	"math"
+	"sync"
+	"time"
+)
+
+// ReportJobName 月度用电报告任务的名称
+const ReportJobName = "monthly_report"
+
+// ReportController 每月统计上个月的用电并推送报告
+type ReportController struct {
+	reportService       service.ReportService
+	notificationService service.NotificationService
+	jobService          service.JobService
+	stopChan            chan struct{}
+	stopOnce            sync.Once
+	cfg                 ReportControllerConfig
+	l                   logger.Logger
+}
+
+type ReportControllerConfig struct {
+	Day    int  `yaml:"day"`    // 每月几号推送
+	Hour   int  `yaml:"hour"`   // 推送的整点,按默认时区计算
+	DryRun bool `yaml:"dryRun"` // 定时任务只统计不推送
+}
+
+func NewReportController(
+	reportService service.ReportService,
+	notificationService service.NotificationService,
+	jobService service.JobService,
+	l logger.Logger,
+) *ReportController {
+	cfg := ReportControllerConfig{Day: 1, Hour: 10}
+	if err := viper.UnmarshalKey("reportController", &cfg); err != nil {
+		panic(err)
+	}
+	c := &ReportController{
+		reportService:       reportService,
+		notificationService: notificationService,
+		jobService:          jobService,
+		stopChan:            make(chan struct{}),
+		cfg:                 cfg,
+		l:                   l,
+	}
+	jobService.RegisterJob(c)
+	return c
+}
+
+func (r *ReportController) StartCronTask(ctx context.Context) {
+	go func() {
+		loc, err := time.LoadLocation(service.DefaultTimezone)
+		if err != nil {
+			loc = time.Local
+		}
+		// 每小时检查一次,到了设定的日期和整点才执行
+		ticker := time.NewTicker(time.Hour)
+		for {
+			select {
+			case now := <-ticker.C:
+				now = now.In(loc)
+				if now.Day() != r.cfg.Day || now.Hour() != r.cfg.Hour {
+					continue
+				}
+				run, err := r.jobService.RunJob(ctx, ReportJobName, r.cfg.DryRun)
+				if err != nil {
+					r.l.Error("推送月度用电报告失败!:", logger.FormatLog("cron", err)...)
+					continue
+				}
+				r.l.Info("推送月度用电报告完成",
+					logger.Int64("checked", run.Checked),
+					logger.Int64("sent", run.Sent),
+					logger.Int64("deferred", run.Deferred),
+				)
+
+			case <-r.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (r *ReportController) StopCronTask() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
+
+func (r *ReportController) Name() string {
+	return ReportJobName
+}
+
+// Run 统计上个月的用电,推送规则和电费提醒相同,免打扰时段内推迟发送
+func (r *ReportController) Run(ctx context.Context, dryRun bool) (domain.JobResult, error) {
+	var res domain.JobResult
+	now := time.Now()
+
+	batch, err := r.reportService.GenerateMonthlyReports(ctx, service.LastMonth(now))
+	if err != nil {
+		return res, err
+	}
+	res.Checked = batch.CheckedRooms
+	res.Failed = int64(len(batch.Errs))
+	errs := batch.Errs
+
+	for _, msg := range batch.MSGs {
+		event := newReportEvent(msg)
+		if dryRun {
+			res.Events = append(res.Events, event)
+			res.Sent++
+			continue
+		}
+
+		at, err := r.notificationService.DeliverAt(ctx, event.StudentId, false, now)
+		if err != nil {
+			r.l.Warn("获取提醒偏好失败", logger.Error(err), logger.String("studentId", event.StudentId))
+		} else if at.After(now) {
+			deferred := &domain.DeferredAlert{
+				Event:     event,
+				Kind:      domain.DeferredKindReport(msg.Report.Current.Month),
+				DeliverAt: at.Unix(),
+			}
+			if err := r.notificationService.Defer(ctx, deferred); err != nil {
+				res.Failed++
+				errs = append(errs, err)
+				continue
+			}
+			res.Deferred++
+			continue
+		}
+
+		if _, _, err := r.notificationService.Send(ctx, event); err != nil {
+			res.Failed++
+			errs = append(errs, err)
+			continue
+		}
+		res.Sent++
+	}
+	return res, errors.Join(errs...)
+}
+
+func newReportEvent(msg *domain.MonthlyReportMSG) *domain.FeedEvent {
+	cur := msg.Report.Current
+	content := fmt.Sprintf("您的房间%s在%s共用电%.2f度,电费%.2f元", cur.RoomName, cur.Month, cur.Value, cur.Money)
+	if prev := msg.Report.Previous; prev != nil && prev.Value > 0 {
+		change := (cur.Value - prev.Value) / prev.Value * 100
+		if change >= 0 {
+			content += fmt.Sprintf(",比上月增加%.1f%%", change)
+		} else {
+			content += fmt.Sprintf(",比上月减少%.1f%%", math.Abs(change))
+		}
+	}
+	if c := msg.Report.Carbon; c.Kg > 0 {
+		content += fmt.Sprintf(",约合二氧化碳%.2f千克,相当于%.2f棵树一年的吸收量", c.Kg, c.Trees)
+	}
+	if b := msg.Report.Building; b.Rooms > 0 {
+		content += fmt.Sprintf(",%s平均用电%.2f度", cur.Building, b.Value)
+	}
+	if c := msg.Report.Campus; c.Rooms > 0 {
+		content += fmt.Sprintf(",全校平均用电%.2f度", c.Value)
+	}
+	return &domain.FeedEvent{
+		StudentId: msg.StudentId,
+		RoomId:    cur.RoomId,
+		Type:      domain.FeedTypeEnergyReport,
+		Title:     "月度用电报告",
+		Content:   content,
+	}
+}
@@ -0,0 +1,114 @@
+package cron
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/service"
+	"github.com/spf13/viper"
+	"sync"
+	"time"
+)
+
+// RetentionJobName 清理毕业学生订阅任务的名称
+const RetentionJobName = "retention"
+
+// RetentionController 定期清理已毕业学生的订阅和过期的电费读数
+type RetentionController struct {
+	retentionService service.RetentionService
+	jobService       service.JobService
+	stopChan         chan struct{}
+	stopOnce         sync.Once
+	cfg              RetentionControllerConfig
+	l                logger.Logger
+}
+
+type RetentionControllerConfig struct {
+	DurationTime   int64 `yaml:"durationTime"`   // 检查周期,单位小时
+	StudyYears     int   `yaml:"studyYears"`     // 学制年数
+	RetainYears    int   `yaml:"retainYears"`    // 毕业后保留的年数
+	PurgeAfterDays int   `yaml:"purgeAfterDays"` // 软删除后多少天彻底删除
+	ReadingDays    int   `yaml:"readingDays"`    // 电费读数保留的天数,为 0 时不清理
+	DryRun         bool  `yaml:"dryRun"`         // 只统计不删除
+}
+
+func NewRetentionController(
+	retentionService service.RetentionService,
+	jobService service.JobService,
+	l logger.Logger,
+) *RetentionController {
+	cfg := RetentionControllerConfig{
+		DurationTime:   24,
+		StudyYears:     4,
+		RetainYears:    2,
+		PurgeAfterDays: 30,
+		ReadingDays:    400,
+	}
+	if err := viper.UnmarshalKey("retentionController", &cfg); err != nil {
+		panic(err)
+	}
+	c := &RetentionController{
+		retentionService: retentionService,
+		jobService:       jobService,
+		stopChan:         make(chan struct{}),
+		cfg:              cfg,
+		l:                l,
+	}
+	jobService.RegisterJob(c)
+	return c
+}
+
+func (r *RetentionController) StartCronTask(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(r.cfg.DurationTime) * time.Hour)
+		for {
+			select {
+			case <-ticker.C:
+				run, err := r.jobService.RunJob(ctx, RetentionJobName, r.cfg.DryRun)
+				if err != nil {
+					r.l.Error("清理毕业学生订阅失败!:", logger.FormatLog("cron", err)...)
+					continue
+				}
+				r.l.Info("清理毕业学生订阅完成",
+					logger.Int64("checked", run.Checked),
+					logger.Int64("deleted", run.Deleted),
+					logger.Int64("purged", run.Purged),
+					logger.Int64("pruned", run.Pruned),
+				)
+
+			case <-r.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (r *RetentionController) StopCronTask() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
+
+func (r *RetentionController) Name() string {
+	return RetentionJobName
+}
+
+func (r *RetentionController) Run(ctx context.Context, dryRun bool) (domain.JobResult, error) {
+	var res domain.JobResult
+	clean, err := r.retentionService.CleanGraduated(ctx, &domain.CleanGraduatedRequest{
+		StudyYears:    r.cfg.StudyYears,
+		RetainYears:   r.cfg.RetainYears,
+		PurgeAfter:    time.Duration(r.cfg.PurgeAfterDays) * 24 * time.Hour,
+		ReadingRetain: time.Duration(r.cfg.ReadingDays) * 24 * time.Hour,
+		DryRun:        dryRun,
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Checked = clean.Checked
+	res.Deleted = clean.Deleted
+	res.Purged = clean.Purged
+	res.Pruned = clean.Pruned
+	return res, nil
+}
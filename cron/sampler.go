@@ -0,0 +1,119 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/service"
+	"sync"
+	"time"
+)
+
+// SamplerJobName 后台采样任务的名称
+const SamplerJobName = "balance_sampler"
+
+// SamplerController 定期读取所有被订阅房间的电费,每轮把请求均匀分散在整个采样间隔内,减轻学校接口的压力
+// 保存的读数同时用于预测、用电异常检测、提醒和实时推送
+type SamplerController struct {
+	elecpriceService service.ElecpriceService
+	jobService       service.JobService
+	stopChan         chan struct{}
+	stopOnce         sync.Once
+	cfg              service.SamplerConfig
+	l                logger.Logger
+}
+
+func NewSamplerController(
+	elecpriceService service.ElecpriceService,
+	jobService service.JobService,
+	l logger.Logger,
+) *SamplerController {
+	c := &SamplerController{
+		elecpriceService: elecpriceService,
+		jobService:       jobService,
+		stopChan:         make(chan struct{}),
+		cfg:              service.LoadSamplerConfig(),
+		l:                l,
+	}
+	jobService.RegisterJob(c)
+	return c
+}
+
+func (r *SamplerController) StartCronTask(ctx context.Context) {
+	if !r.cfg.Enabled {
+		return
+	}
+	go func() {
+		interval := time.Duration(r.cfg.IntervalMinutes) * time.Minute
+		for {
+			start := time.Now()
+			_, err := r.jobService.RunJob(ctx, SamplerJobName, r.cfg.DryRun)
+			if err != nil {
+				r.l.Error("后台采样失败!:", logger.FormatLog("cron", err)...)
+			}
+
+			// 每轮最多持续一个间隔,提前结束时等到间隔结束再开始下一轮
+			select {
+			case <-time.After(time.Until(start.Add(interval))):
+			case <-r.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (r *SamplerController) StopCronTask() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
+
+func (r *SamplerController) Name() string {
+	return SamplerJobName
+}
+
+func (r *SamplerController) Run(ctx context.Context, dryRun bool) (domain.JobResult, error) {
+	var (
+		res  domain.JobResult
+		errs []error
+	)
+
+	rooms, err := r.elecpriceService.SubscribedRooms(ctx)
+	if err != nil {
+		return res, err
+	}
+	res.Checked = int64(len(rooms))
+	if dryRun || len(rooms) == 0 {
+		return res, nil
+	}
+
+	spacing := time.Duration(r.cfg.IntervalMinutes) * time.Minute / time.Duration(len(rooms))
+	for i, roomId := range rooms {
+		if i > 0 && !r.wait(ctx, spacing) {
+			break
+		}
+		// GetPrice 会保存读数,并通知正在订阅该房间的客户端
+		if _, err := r.elecpriceService.GetPrice(ctx, roomId); err != nil {
+			res.Failed++
+			errs = append(errs, err)
+			continue
+		}
+		res.Sampled++
+	}
+	return res, errors.Join(errs...)
+}
+
+// wait 等待 d,任务被取消或定时任务停止时返回 false
+func (r *SamplerController) wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-r.stopChan:
+		return false
+	}
+}
@@ -1,14 +1,42 @@
 package domain
 
+import "time"
+
 type Elecprice struct {
 	Airconditioner *Prices `json:"airconditioner"`
 	Lighting       *Prices `json:"lighting"`
 }
 
 type ElectricMSG struct {
-	RoomName  *string
-	StudentId string // 学号
-	Remain    *string
+	RoomId      string
+	RoomName    *string
+	StudentId   string // 学号
+	Remain      *string
+	Limit       int64  // 触发的阈值
+	Severity    string // 触发阈值的严重程度
+	Template    string // 触发阈值的提醒内容模板
+	ThresholdID int64  // 触发的阈值,旧版单阈值订阅为 0
+	GroupId     int64  // 来自房间群组的提醒,个人订阅为 0
+	NextRotate  int64  // 群组的提醒发出后,群组下一次轮流提醒的成员序号
+	// 用电异常提醒的检测结果和对应的订阅,电费不足提醒为 nil
+	Anomaly        *Anomaly
+	SubscriptionID int64
+}
+
+// Anomaly 某一天的用电量远高于该房间平时的用电
+type Anomaly struct {
+	Day    string  // 用电异常的日期,YYYY-MM-DD
+	Usage  float64 // 当天用电量,单位度
+	Mean   float64 // 之前若干天的平均用电量
+	Std    float64 // 之前若干天用电量的标准差
+	ZScore float64 // (Usage - Mean) / Std
+}
+
+// ElectricMSGBatch 一次电费检查的结果
+type ElectricMSGBatch struct {
+	CheckedRooms int64          // 检查的房间数
+	MSGs         []*ElectricMSG // 需要推送的消息
+	Errs         []error        // 检查失败的房间产生的错误
 }
 
 type ResultInfo struct {
@@ -42,12 +70,135 @@ type Prices struct {
 	RemainMoney       string
 	YesterdayUseValue string
 	YesterdayUseMoney string
+	YesterdayCarbon   Carbon // 昨日用电对应的碳排放
+}
+
+// Carbon 用电对应的碳排放
+type Carbon struct {
+	Kg    float64 // CO2 当量,单位千克
+	Trees float64 // 相当于多少棵树一年的吸收量
+}
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+	// SeverityAnomaly 用电异常提醒单独使用的类型,不能用于阈值
+	SeverityAnomaly = "anomaly"
+)
+
+// Threshold 订阅中的一级提醒阈值,余额低于 Limit 时触发
+type Threshold struct {
+	ID          int64
+	Limit       int64
+	Severity    string
+	Template    string // 提醒内容模板,为空时使用默认内容
+	Cooldown    int64  // 两次提醒的最小间隔,单位秒
+	LastAlertAt int64
+}
+
+// Subscription 学生对房间电费的订阅
+type Subscription struct {
+	ID         int64
+	StudentId  string
+	RoomId     string
+	RoomName   string
+	Limit      int64        // 旧版的单一阈值,没有设置 Thresholds 时使用
+	Thresholds []*Threshold // 按设置顺序排列
+	// 用电异常提醒,AnomalyZScore 为 0 时使用默认的灵敏度
+	AnomalyAlert     bool
+	AnomalyZScore    float64
+	AnomalyAlertedAt int64
+}
+
+// 房间群组的提醒方式
+const (
+	DeliveryModeAll    = "all"    // 提醒所有成员
+	DeliveryModeRotate = "rotate" // 每次轮流提醒一位成员
+)
+
+// RoomGroup 室友共享的房间订阅,成员通过邀请码加入,阈值属于整个群组
+type RoomGroup struct {
+	ID           int64
+	RoomId       string
+	RoomName     string
+	OwnerId      string
+	InviteCode   string
+	DeliveryMode string
+	RotateCursor int64
+	Members      []string // 按加入顺序排列,第一个为创建者
+	Thresholds   []*Threshold
+}
+
+// Recharge 根据余额上升检测到的一次充值
+type Recharge struct {
+	ID         int64
+	RoomId     string
+	Amount     float64
+	Before     float64
+	After      float64
+	DetectedAt int64
+	PaidBy     string // 认领的学生,未认领时为空
+	ReadingId  int64  // 检测充值时对比的上一次读数
+}
+
+// MemberSettlement 群组成员在结算周期内的账目,Balance 为正表示其他成员欠他的钱
+type MemberSettlement struct {
+	StudentId string
+	Paid      float64 // 认领的充值金额
+	Share     float64 // 平摊的用电金额
+	Balance   float64 // Paid - Share
+}
+
+// Settlement 房间群组在一个周期内的分摊结算
+type Settlement struct {
+	GroupId   int64
+	RoomId    string
+	Period    string // YYYY-MM
+	From      int64
+	To        int64
+	Consumed  float64 // 周期内的用电金额
+	Recharged float64 // 周期内的充值总额
+	Unclaimed float64 // 还没有人认领的充值金额,不计入成员的账目
+	Members   []*MemberSettlement
+	Recharges []*Recharge
+}
+
+// Reading 一次电费读数
+// RoomPrice 批量查询中一个房间的结果,Err 不为空时 Price 为 nil
+type RoomPrice struct {
+	RoomId string
+	Price  *Prices
+	Err    error
+}
+
+type Reading struct {
+	ID                int64
+	RoomId            string
+	RemainMoney       float64
+	YesterdayUseValue float64
+	YesterdayUseMoney float64
+	ReadAt            int64
+}
+
+type WatchPriceRequest struct {
+	StudentId string
+	RoomIds   []string
+}
+
+// WatchPriceEvent 实时电费推送的一条消息,Heartbeat 为 true 时没有读数
+type WatchPriceEvent struct {
+	Reading   *Reading
+	Heartbeat bool
 }
 
 type Standard struct {
-	Limit    int64
-	RoomId   string
-	RoomName string
+	Limit         int64
+	RoomId        string
+	RoomName      string
+	Thresholds    []*Threshold
+	AnomalyAlert  bool    // 用电量远高于平时时提醒
+	AnomalyZScore float64 // 判定异常的标准分数,越小越灵敏,0 表示使用默认值
 }
 
 type SetStandardRequest struct {
@@ -66,9 +217,396 @@ type GetStandardListResponse struct {
 	Standard []*Standard
 }
 
+type CreateRoomGroupRequest struct {
+	StudentId    string
+	RoomId       string
+	RoomName     string
+	DeliveryMode string
+	Thresholds   []*Threshold
+}
+
+type JoinRoomGroupRequest struct {
+	StudentId  string
+	InviteCode string
+}
+
+type LeaveRoomGroupRequest struct {
+	StudentId string
+	GroupId   int64
+}
+
+type UpdateRoomGroupRequest struct {
+	StudentId    string
+	GroupId      int64
+	DeliveryMode string
+	Thresholds   []*Threshold
+}
+
+type DeleteRoomGroupRequest struct {
+	StudentId string
+	GroupId   int64
+}
+
+type ListRoomGroupsRequest struct {
+	StudentId string
+}
+
+type ListRoomGroupsResponse struct {
+	Groups []*RoomGroup
+}
+
+type GetSettlementRequest struct {
+	StudentId string
+	GroupId   int64
+	Period    string // YYYY-MM,为空时为当月
+}
+
+type ClaimRechargeRequest struct {
+	StudentId  string
+	GroupId    int64
+	RechargeId int64
+}
+
+// MonthlyUsage 房间一个月的用电
+type MonthlyUsage struct {
+	RoomId   string
+	RoomName string
+	Building string // 由房间名称推断,无法推断时为空
+	Month    string // YYYY-MM
+	Value    float64
+	Money    float64
+	Days     int64 // 有用电数据的天数
+}
+
+// UsageAverage 同一个月有用电数据的房间的平均用电
+type UsageAverage struct {
+	Value float64
+	Money float64
+	Rooms int64 // 参与统计的房间数
+}
+
+// MonthlyReport 月度用电报告
+type MonthlyReport struct {
+	Current  *MonthlyUsage
+	Previous *MonthlyUsage // 上个月没有数据时为 nil
+	Building UsageAverage  // 同楼栋的平均用电,楼栋未知时为空
+	Campus   UsageAverage
+	// 当月用电和楼栋、全校平均用电对应的碳排放
+	Carbon         Carbon
+	BuildingCarbon Carbon
+	CampusCarbon   Carbon
+}
+
+type MonthlyReportMSG struct {
+	StudentId string
+	Report    *MonthlyReport
+}
+
+type MonthlyReportBatch struct {
+	CheckedRooms int64               // 统计的房间数
+	MSGs         []*MonthlyReportMSG // 需要推送的报告,订阅了同一个房间的学生各一份
+	Errs         []error             // 统计失败的房间产生的错误
+}
+
+type GetMonthlyReportRequest struct {
+	StudentId string
+	RoomId    string
+	Month     string // YYYY-MM,为空时为上个月
+}
+
+// BuildingStats 楼栋或区域最近几天的用电统计
+type BuildingStats struct {
+	Area             string
+	ArchitectureId   string // 区域统计时为空
+	ArchitectureName string
+	Rooms            int64   // 目录中的房间数
+	RoomsWithData    int64   // 统计周期内有读数的房间数
+	MeanDailyUsage   float64 // 每个房间日均用电量的平均值,单位度
+	MedianDailyUsage float64 // 每个房间日均用电量的中位数
+	NearZero         int64   // 余额接近耗尽的房间数
+	Balances         []BalanceBucket
+	Architectures    []*BuildingStats // 区域统计时每个楼栋的统计
+}
+
+// BalanceBucket 按最近一次读数的余额分段统计房间数
+type BalanceBucket struct {
+	Label string // 如 0-10,100+
+	Rooms int64
+}
+
+type GetBuildingStatsRequest struct {
+	Area           string
+	ArchitectureId string // 为空时统计整个区域
+}
+
+// LeaderboardRoom 报名参加节能排行榜的房间
+type LeaderboardRoom struct {
+	RoomId         string
+	RoomName       string
+	ArchitectureId string
+	StudentId      string // 报名的学生
+}
+
+type LeaderboardEntry struct {
+	Rank       int64 // 日均用电相同的房间名次相同
+	RoomId     string
+	RoomName   string
+	DailyUsage float64
+}
+
+type JoinLeaderboardRequest struct {
+	StudentId      string
+	RoomId         string
+	Area           string
+	ArchitectureId string
+}
+
+type LeaveLeaderboardRequest struct {
+	StudentId string
+	RoomId    string
+}
+
+type GetLeaderboardRequest struct {
+	ArchitectureId string
+	Limit          int
+}
+
+// 导出用电记录的格式
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json" // 每行一个 JSON 对象
+)
+
+// UsageRecord 房间一天的用电记录
+type UsageRecord struct {
+	Date    string  `json:"date"`    // YYYY-MM-DD
+	Value   float64 `json:"kwh"`     // 用电量,单位度
+	Money   float64 `json:"money"`   // 电费,单位元
+	Balance float64 `json:"balance"` // 读到该日用电时的余额
+}
+
+type ExportUsageRequest struct {
+	StudentId string
+	RoomId    string
+	From      string // YYYY-MM-DD,为空时为 To 之前 30 天
+	To        string // YYYY-MM-DD,包含当天,为空时为昨天
+	Format    string // csv 或 json,为空时为 csv
+}
+
 type CancelStandardRequest struct {
 	StudentId string
 	RoomId    string
 }
 
+// Snooze 学生暂停某个房间的提醒,到期或电费继续下降超过设定幅度后恢复
+type Snooze struct {
+	ID        int64
+	StudentId string
+	RoomId    string
+	Until     int64   // 暂停截止时间,0 表示确认提醒,不按时间恢复
+	Remain    float64 // 暂停时的电费余额
+}
+
+type AcknowledgeAlertRequest struct {
+	StudentId string
+	RoomId    string
+}
+
+type SnoozeStandardRequest struct {
+	StudentId string
+	RoomId    string
+	Until     int64
+}
+
 type CancelStandardResponse struct{}
+
+const (
+	JobStatusRunning = "running"
+	JobStatusSuccess = "success"
+	JobStatusFailed  = "failed"
+)
+
+// JobStats 一次任务执行的统计
+type JobStats struct {
+	Checked  int64 // 检查的房间数
+	Sent     int64 // 发出的提醒数,试运行时为将要发出的提醒数
+	Failed   int64 // 失败数
+	Deleted  int64 // 软删除的订阅数
+	Purged   int64 // 彻底删除的订阅数
+	Deferred int64 // 因免打扰或推送时间推迟的提醒数
+	Sampled  int64 // 后台采样成功的房间数
+	Pruned   int64 // 清理(试运行时为将要清理)的过期读数数
+}
+
+// JobResult 一次任务执行的结果
+type JobResult struct {
+	JobStats
+	Events []*FeedEvent // 试运行时将要发出的提醒
+}
+
+// FeedEvent 推送给 feed 服务的提醒
+type FeedEvent struct {
+	StudentId string
+	RoomId    string // 提醒对应的房间,不会推送给 feed 服务
+	DeliverAt int64  // 试运行时因免打扰或推送时间推迟到的时间,为 0 表示立即发送,不会推送给 feed 服务
+	Type      string
+	Title     string
+	Content   string
+}
+
+// feed 事件的类型
+const (
+	FeedTypeEnergy        = "energy"         // 余额低于阈值
+	FeedTypeEnergyAnomaly = "energy_anomaly" // 用电异常
+	FeedTypeEnergyReport  = "energy_report"  // 月度用电报告
+)
+
+// NotificationPreference 学生的提醒偏好,时间均为 HH:MM 格式,为空表示不设置
+type NotificationPreference struct {
+	StudentId     string
+	QuietStart    string // 免打扰开始时间,可以跨过零点,如 23:00
+	QuietEnd      string // 免打扰结束时间,如 07:00
+	PreferredTime string // 每日推送时间,设置后非紧急提醒都在这个时间推送
+	Timezone      string // 默认 Asia/Shanghai
+	Locale        string // 提醒使用的语言,为空时使用默认语言
+	Channel       string // 提醒渠道 feed/webhook/email,为空时使用默认渠道
+	Address       string // 渠道地址,webhook 地址或邮箱,feed 渠道不需要
+}
+
+// DeferredAlert 因免打扰或推送时间被推迟的提醒
+type DeferredAlert struct {
+	ID        int64
+	Event     *FeedEvent
+	Kind      string // 提醒的种类,同一学生同一房间只有同一种类的提醒会相互替换
+	DeliverAt int64
+	HistoryID int64 // 对应的提醒历史记录
+}
+
+// 推迟的提醒的种类
+const (
+	DeferredKindThreshold = "threshold"
+	DeferredKindAnomaly   = "anomaly"
+)
+
+// DeferredKindReport 月度报告的种类,不同月份的报告互不替换
+func DeferredKindReport(month string) string {
+	return "report:" + month
+}
+
+// 提醒的发送状态
+const (
+	AlertStatusSent     = "sent"
+	AlertStatusFailed   = "failed"
+	AlertStatusDeferred = "deferred"
+	// AlertStatusSuperseded 推迟的提醒在发送前被同一种类的新提醒替换
+	AlertStatusSuperseded = "superseded"
+)
+
+// AlertRecord 发给学生的一条提醒的历史记录
+type AlertRecord struct {
+	ID           int64
+	StudentId    string
+	RoomId       string
+	RoomName     string
+	Remain       string // 提醒时的电费余额
+	Limit        int64  // 触发的阈值
+	Severity     string
+	Title        string
+	Content      string
+	Status       string
+	Channel      string // 实际使用的提醒渠道
+	FeedResponse string // 渠道的返回或错误信息
+	CreatedAt    int64
+	DeliveredAt  int64
+}
+
+type ListAlertHistoryRequest struct {
+	StudentId string
+	Offset    int
+	Limit     int
+}
+
+type ListAlertHistoryResponse struct {
+	Records []*AlertRecord
+}
+
+// PreviewTemplateRequest Title 和 Content 为空时预览已配置的模板,RoomId 为空时使用示例数据
+type PreviewTemplateRequest struct {
+	Severity string
+	Locale   string
+	Title    string
+	Content  string
+	RoomId   string
+}
+
+type PreviewTemplateResponse struct {
+	Title   string
+	Content string
+}
+
+type GetNotificationPreferenceRequest struct {
+	StudentId string
+}
+
+type GetNotificationPreferenceResponse struct {
+	Preference *NotificationPreference
+}
+
+type SetNotificationPreferenceRequest struct {
+	Preference *NotificationPreference
+}
+
+type JobRun struct {
+	ID        int64
+	Name      string
+	DryRun    bool
+	Status    string
+	StartedAt int64
+	EndedAt   int64
+	JobStats
+	Error  string
+	Events []*FeedEvent // 仅同步试运行时返回
+}
+
+type ListJobRunsRequest struct {
+	Name   string
+	Offset int
+	Limit  int
+}
+
+type ListJobRunsResponse struct {
+	JobRuns []*JobRun
+}
+
+type TriggerJobRequest struct {
+	Name   string
+	DryRun bool
+}
+
+type TriggerJobResponse struct {
+	JobRun *JobRun
+}
+
+type DryRunJobRequest struct {
+	Name string
+}
+
+type DryRunJobResponse struct {
+	JobRun *JobRun
+}
+
+type CleanGraduatedRequest struct {
+	StudyYears  int           // 学制年数
+	RetainYears int           // 毕业后保留的年数
+	PurgeAfter  time.Duration // 软删除后多久彻底删除
+	// ReadingRetain 电费读数的保留时长,为 0 时不清理
+	ReadingRetain time.Duration
+	DryRun        bool // 只统计不删除
+}
+
+type CleanGraduatedResponse struct {
+	Checked int64 // 检查的订阅数
+	Deleted int64 // 软删除(试运行时为将要软删除)的订阅数
+	Purged  int64 // 彻底删除(试运行时为将要彻底删除)的订阅数
+	Pruned  int64 // 清理(试运行时为将要清理)的过期读数数
+}
@@ -0,0 +1,39 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) ListAlertHistory(ctx context.Context, req *v1.ListAlertHistoryRequest) (*v1.ListAlertHistoryResponse, error) {
+	res, err := s.alertHistorySer.ListAlertHistory(ctx, &domain.ListAlertHistoryRequest{
+		StudentId: req.StudentId,
+		Offset:    int(req.Offset),
+		Limit:     int(req.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp v1.ListAlertHistoryResponse
+	for _, r := range res.Records {
+		resp.Records = append(resp.Records, &v1.AlertRecord{
+			Id:           r.ID,
+			StudentId:    r.StudentId,
+			RoomId:       r.RoomId,
+			RoomName:     r.RoomName,
+			Remain:       r.Remain,
+			Limit:        r.Limit,
+			Severity:     r.Severity,
+			Title:        r.Title,
+			Content:      r.Content,
+			Status:       r.Status,
+			Channel:      r.Channel,
+			FeedResponse: r.FeedResponse,
+			CreatedAt:    r.CreatedAt,
+			DeliveredAt:  r.DeliveredAt,
+		})
+	}
+	return &resp, nil
+}
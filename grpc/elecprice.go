@@ -11,11 +11,45 @@ import (
 type ElecpriceServiceServer struct {
 	v1.UnimplementedElecpriceServiceServer
 
-	ser service.ElecpriceService
+	ser             service.ElecpriceService
+	jobSer          service.JobService
+	notificationSer service.NotificationService
+	alertHistorySer service.AlertHistoryService
+	templateSer     service.TemplateService
+	groupSer        service.RoomGroupService
+	settlementSer   service.SettlementService
+	reportSer       service.ReportService
+	statsSer        service.StatsService
+	exportSer       service.ExportService
+	watchSer        service.WatchService
 }
 
-func NewElecpriceGrpcService(ser service.ElecpriceService) *ElecpriceServiceServer {
-	return &ElecpriceServiceServer{ser: ser}
+func NewElecpriceGrpcService(
+	ser service.ElecpriceService,
+	jobSer service.JobService,
+	notificationSer service.NotificationService,
+	alertHistorySer service.AlertHistoryService,
+	templateSer service.TemplateService,
+	groupSer service.RoomGroupService,
+	settlementSer service.SettlementService,
+	reportSer service.ReportService,
+	statsSer service.StatsService,
+	exportSer service.ExportService,
+	watchSer service.WatchService,
+) *ElecpriceServiceServer {
+	return &ElecpriceServiceServer{
+		ser:             ser,
+		jobSer:          jobSer,
+		notificationSer: notificationSer,
+		alertHistorySer: alertHistorySer,
+		templateSer:     templateSer,
+		groupSer:        groupSer,
+		settlementSer:   settlementSer,
+		reportSer:       reportSer,
+		statsSer:        statsSer,
+		exportSer:       exportSer,
+		watchSer:        watchSer,
+	}
 }
 
 func (s *ElecpriceServiceServer) Register(server grpc.ServiceRegistrar) {
@@ -67,17 +101,46 @@ func (s *ElecpriceServiceServer) GetPrice(ctx context.Context, req *v1.GetPriceR
 			RemainMoney:       res.RemainMoney,
 			YesterdayUseValue: res.YesterdayUseValue,
 			YesterdayUseMoney: res.YesterdayUseMoney,
+			YesterdayCarbon:   toV1Carbon(res.YesterdayCarbon),
 		},
 	}, nil
 }
 
+func (s *ElecpriceServiceServer) BatchGetPrice(ctx context.Context, req *v1.BatchGetPriceRequest) (*v1.BatchGetPriceResponse, error) {
+	res, err := s.ser.BatchGetPrice(ctx, req.RoomIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp v1.BatchGetPriceResponse
+	for _, r := range res {
+		price := &v1.RoomPrice{RoomId: r.RoomId}
+		if r.Err != nil {
+			price.Error = r.Err.Error()
+		} else {
+			price.Price = &v1.GetPriceResponse_Price{
+				RemainMoney:       r.Price.RemainMoney,
+				YesterdayUseValue: r.Price.YesterdayUseValue,
+				YesterdayUseMoney: r.Price.YesterdayUseMoney,
+				YesterdayCarbon:   toV1Carbon(r.Price.YesterdayCarbon),
+			}
+		}
+		resp.Prices = append(resp.Prices, price)
+	}
+	return &resp, nil
+}
+
 func (s *ElecpriceServiceServer) SetStandard(ctx context.Context, req *v1.SetStandardRequest) (*v1.SetStandardResponse, error) {
 	err := s.ser.SetStandard(ctx, &domain.SetStandardRequest{
 		StudentId: req.StudentId,
 		Standard: &domain.Standard{
-			Limit:    req.Standard.Limit,
-			RoomId:   req.Standard.RoomId,
-			RoomName: req.Standard.RoomName,
+			Limit:      req.Standard.Limit,
+			RoomId:     req.Standard.RoomId,
+			RoomName:   req.Standard.RoomName,
+			Thresholds: toDomainThresholds(req.Standard.Thresholds),
+
+			AnomalyAlert:  req.Standard.AnomalyAlert,
+			AnomalyZScore: req.Standard.AnomalyZScore,
 		},
 	})
 
@@ -95,9 +158,13 @@ func (s *ElecpriceServiceServer) GetStandardList(ctx context.Context, req *v1.Ge
 	var resp v1.GetStandardListResponse
 	for _, s := range res.Standard {
 		resp.Standards = append(resp.Standards, &v1.Standard{
-			Limit:    s.Limit,
-			RoomId:   s.RoomId,
-			RoomName: s.RoomName,
+			Limit:      s.Limit,
+			RoomId:     s.RoomId,
+			RoomName:   s.RoomName,
+			Thresholds: toV1Thresholds(s.Thresholds),
+
+			AnomalyAlert:  s.AnomalyAlert,
+			AnomalyZScore: s.AnomalyZScore,
 		})
 	}
 	return &resp, nil
@@ -111,3 +178,33 @@ func (s *ElecpriceServiceServer) CancelStandard(ctx context.Context, req *v1.Can
 
 	return &v1.CancelStandardResponse{}, err
 }
+
+func toDomainThresholds(thresholds []*v1.Threshold) []*domain.Threshold {
+	res := make([]*domain.Threshold, 0, len(thresholds))
+	for _, t := range thresholds {
+		res = append(res, &domain.Threshold{
+			Limit:    t.Limit,
+			Severity: t.Severity,
+			Template: t.Template,
+			Cooldown: t.CooldownSeconds,
+		})
+	}
+	return res
+}
+
+func toV1Thresholds(thresholds []*domain.Threshold) []*v1.Threshold {
+	res := make([]*v1.Threshold, 0, len(thresholds))
+	for _, t := range thresholds {
+		res = append(res, &v1.Threshold{
+			Limit:           t.Limit,
+			Severity:        t.Severity,
+			Template:        t.Template,
+			CooldownSeconds: t.Cooldown,
+		})
+	}
+	return res
+}
+
+func toV1Carbon(c domain.Carbon) *v1.Carbon {
+	return &v1.Carbon{Kg: c.Kg, Trees: c.Trees}
+}
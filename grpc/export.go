@@ -0,0 +1,18 @@
+package grpc
+
+import (
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) ExportUsage(req *v1.ExportUsageRequest, stream v1.ElecpriceService_ExportUsageServer) error {
+	return s.exportSer.ExportUsage(stream.Context(), &domain.ExportUsageRequest{
+		StudentId: req.StudentId,
+		RoomId:    req.RoomId,
+		From:      req.From,
+		To:        req.To,
+		Format:    req.Format,
+	}, func(chunk []byte) error {
+		return stream.Send(&v1.ExportUsageResponse{Data: chunk})
+	})
+}
@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) ListJobRuns(ctx context.Context, req *v1.ListJobRunsRequest) (*v1.ListJobRunsResponse, error) {
+	res, err := s.jobSer.ListJobRuns(ctx, &domain.ListJobRunsRequest{
+		Name:   req.Name,
+		Offset: int(req.Offset),
+		Limit:  int(req.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp v1.ListJobRunsResponse
+	for _, r := range res.JobRuns {
+		resp.JobRuns = append(resp.JobRuns, toV1JobRun(r))
+	}
+	return &resp, nil
+}
+
+func (s *ElecpriceServiceServer) TriggerJob(ctx context.Context, req *v1.TriggerJobRequest) (*v1.TriggerJobResponse, error) {
+	res, err := s.jobSer.TriggerJob(ctx, &domain.TriggerJobRequest{
+		Name:   req.Name,
+		DryRun: req.DryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.TriggerJobResponse{JobRun: toV1JobRun(res.JobRun)}, nil
+}
+
+func (s *ElecpriceServiceServer) DryRunJob(ctx context.Context, req *v1.DryRunJobRequest) (*v1.DryRunJobResponse, error) {
+	res, err := s.jobSer.DryRunJob(ctx, &domain.DryRunJobRequest{
+		Name: req.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := v1.DryRunJobResponse{JobRun: toV1JobRun(res.JobRun)}
+	for _, e := range res.JobRun.Events {
+		resp.Events = append(resp.Events, &v1.FeedEvent{
+			StudentId: e.StudentId,
+			Type:      e.Type,
+			Title:     e.Title,
+			Content:   e.Content,
+			DeliverAt: e.DeliverAt,
+		})
+	}
+	return &resp, nil
+}
+
+func toV1JobRun(r *domain.JobRun) *v1.JobRun {
+	return &v1.JobRun{
+		Id:        r.ID,
+		Name:      r.Name,
+		DryRun:    r.DryRun,
+		Status:    r.Status,
+		StartedAt: r.StartedAt,
+		EndedAt:   r.EndedAt,
+		Checked:   r.Checked,
+		Sent:      r.Sent,
+		Failed:    r.Failed,
+		Deleted:   r.Deleted,
+		Purged:    r.Purged,
+		Deferred:  r.Deferred,
+		Sampled:   r.Sampled,
+		Pruned:    r.Pruned,
+		Error:     r.Error,
+	}
+}
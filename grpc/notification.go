@@ -0,0 +1,52 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) GetNotificationPreference(ctx context.Context, req *v1.GetNotificationPreferenceRequest) (*v1.GetNotificationPreferenceResponse, error) {
+	res, err := s.notificationSer.GetPreference(ctx, &domain.GetNotificationPreferenceRequest{
+		StudentId: req.StudentId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := res.Preference
+	return &v1.GetNotificationPreferenceResponse{
+		Preference: &v1.NotificationPreference{
+			StudentId:     p.StudentId,
+			QuietStart:    p.QuietStart,
+			QuietEnd:      p.QuietEnd,
+			PreferredTime: p.PreferredTime,
+			Timezone:      p.Timezone,
+			Locale:        p.Locale,
+			Channel:       p.Channel,
+			Address:       p.Address,
+		},
+	}, nil
+}
+
+func (s *ElecpriceServiceServer) SetNotificationPreference(ctx context.Context, req *v1.SetNotificationPreferenceRequest) (*v1.SetNotificationPreferenceResponse, error) {
+	var pref *domain.NotificationPreference
+	if p := req.Preference; p != nil {
+		pref = &domain.NotificationPreference{
+			StudentId:     p.StudentId,
+			QuietStart:    p.QuietStart,
+			QuietEnd:      p.QuietEnd,
+			PreferredTime: p.PreferredTime,
+			Timezone:      p.Timezone,
+			Locale:        p.Locale,
+			Channel:       p.Channel,
+			Address:       p.Address,
+		}
+	}
+
+	err := s.notificationSer.SetPreference(ctx, &domain.SetNotificationPreferenceRequest{Preference: pref})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.SetNotificationPreferenceResponse{}, nil
+}
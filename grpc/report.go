@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) GetMonthlyReport(ctx context.Context, req *v1.GetMonthlyReportRequest) (*v1.GetMonthlyReportResponse, error) {
+	res, err := s.reportSer.GetMonthlyReport(ctx, &domain.GetMonthlyReportRequest{
+		StudentId: req.StudentId,
+		RoomId:    req.RoomId,
+		Month:     req.Month,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &v1.MonthlyReport{
+		Current: toUsage(res.Current),
+		Building: &v1.UsageAverage{
+			Value: res.Building.Value,
+			Money: res.Building.Money,
+			Rooms: res.Building.Rooms,
+		},
+		Campus: &v1.UsageAverage{
+			Value: res.Campus.Value,
+			Money: res.Campus.Money,
+			Rooms: res.Campus.Rooms,
+		},
+		Carbon:         toV1Carbon(res.Carbon),
+		BuildingCarbon: toV1Carbon(res.BuildingCarbon),
+		CampusCarbon:   toV1Carbon(res.CampusCarbon),
+	}
+	if res.Previous != nil {
+		report.Previous = toUsage(res.Previous)
+	}
+	return &v1.GetMonthlyReportResponse{Report: report}, nil
+}
+
+func toUsage(u *domain.MonthlyUsage) *v1.MonthlyUsage {
+	return &v1.MonthlyUsage{
+		RoomId:   u.RoomId,
+		RoomName: u.RoomName,
+		Building: u.Building,
+		Month:    u.Month,
+		Value:    u.Value,
+		Money:    u.Money,
+		Days:     u.Days,
+	}
+}
@@ -0,0 +1,95 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) CreateRoomGroup(ctx context.Context, req *v1.CreateRoomGroupRequest) (*v1.CreateRoomGroupResponse, error) {
+	g, err := s.groupSer.CreateGroup(ctx, &domain.CreateRoomGroupRequest{
+		StudentId:    req.StudentId,
+		RoomId:       req.RoomId,
+		RoomName:     req.RoomName,
+		DeliveryMode: req.DeliveryMode,
+		Thresholds:   toDomainThresholds(req.Thresholds),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.CreateRoomGroupResponse{Group: toV1RoomGroup(g)}, nil
+}
+
+func (s *ElecpriceServiceServer) JoinRoomGroup(ctx context.Context, req *v1.JoinRoomGroupRequest) (*v1.JoinRoomGroupResponse, error) {
+	g, err := s.groupSer.JoinGroup(ctx, &domain.JoinRoomGroupRequest{
+		StudentId:  req.StudentId,
+		InviteCode: req.InviteCode,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.JoinRoomGroupResponse{Group: toV1RoomGroup(g)}, nil
+}
+
+func (s *ElecpriceServiceServer) LeaveRoomGroup(ctx context.Context, req *v1.LeaveRoomGroupRequest) (*v1.LeaveRoomGroupResponse, error) {
+	err := s.groupSer.LeaveGroup(ctx, &domain.LeaveRoomGroupRequest{
+		StudentId: req.StudentId,
+		GroupId:   req.GroupId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.LeaveRoomGroupResponse{}, nil
+}
+
+func (s *ElecpriceServiceServer) UpdateRoomGroup(ctx context.Context, req *v1.UpdateRoomGroupRequest) (*v1.UpdateRoomGroupResponse, error) {
+	err := s.groupSer.UpdateGroup(ctx, &domain.UpdateRoomGroupRequest{
+		StudentId:    req.StudentId,
+		GroupId:      req.GroupId,
+		DeliveryMode: req.DeliveryMode,
+		Thresholds:   toDomainThresholds(req.Thresholds),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.UpdateRoomGroupResponse{}, nil
+}
+
+func (s *ElecpriceServiceServer) DeleteRoomGroup(ctx context.Context, req *v1.DeleteRoomGroupRequest) (*v1.DeleteRoomGroupResponse, error) {
+	err := s.groupSer.DeleteGroup(ctx, &domain.DeleteRoomGroupRequest{
+		StudentId: req.StudentId,
+		GroupId:   req.GroupId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.DeleteRoomGroupResponse{}, nil
+}
+
+func (s *ElecpriceServiceServer) ListRoomGroups(ctx context.Context, req *v1.ListRoomGroupsRequest) (*v1.ListRoomGroupsResponse, error) {
+	res, err := s.groupSer.ListGroups(ctx, &domain.ListRoomGroupsRequest{
+		StudentId: req.StudentId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp v1.ListRoomGroupsResponse
+	for _, g := range res.Groups {
+		resp.Groups = append(resp.Groups, toV1RoomGroup(g))
+	}
+	return &resp, nil
+}
+
+func toV1RoomGroup(g *domain.RoomGroup) *v1.RoomGroup {
+	return &v1.RoomGroup{
+		Id:           g.ID,
+		RoomId:       g.RoomId,
+		RoomName:     g.RoomName,
+		OwnerId:      g.OwnerId,
+		InviteCode:   g.InviteCode,
+		DeliveryMode: g.DeliveryMode,
+		Members:      g.Members,
+		Thresholds:   toV1Thresholds(g.Thresholds),
+	}
+}
@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) GetSettlement(ctx context.Context, req *v1.GetSettlementRequest) (*v1.GetSettlementResponse, error) {
+	res, err := s.settlementSer.GetSettlement(ctx, &domain.GetSettlementRequest{
+		StudentId: req.StudentId,
+		GroupId:   req.GroupId,
+		Period:    req.Period,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	settlement := &v1.Settlement{
+		GroupId:   res.GroupId,
+		RoomId:    res.RoomId,
+		Period:    res.Period,
+		From:      res.From,
+		To:        res.To,
+		Consumed:  res.Consumed,
+		Recharged: res.Recharged,
+		Unclaimed: res.Unclaimed,
+	}
+	for _, m := range res.Members {
+		settlement.Members = append(settlement.Members, &v1.MemberSettlement{
+			StudentId: m.StudentId,
+			Paid:      m.Paid,
+			Share:     m.Share,
+			Balance:   m.Balance,
+		})
+	}
+	for _, r := range res.Recharges {
+		settlement.Recharges = append(settlement.Recharges, &v1.Recharge{
+			Id:         r.ID,
+			Amount:     r.Amount,
+			Before:     r.Before,
+			After:      r.After,
+			DetectedAt: r.DetectedAt,
+			PaidBy:     r.PaidBy,
+		})
+	}
+	return &v1.GetSettlementResponse{Settlement: settlement}, nil
+}
+
+func (s *ElecpriceServiceServer) ClaimRecharge(ctx context.Context, req *v1.ClaimRechargeRequest) (*v1.ClaimRechargeResponse, error) {
+	err := s.settlementSer.ClaimRecharge(ctx, &domain.ClaimRechargeRequest{
+		StudentId:  req.StudentId,
+		GroupId:    req.GroupId,
+		RechargeId: req.RechargeId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.ClaimRechargeResponse{}, nil
+}
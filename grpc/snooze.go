@@ -0,0 +1,30 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) AcknowledgeAlert(ctx context.Context, req *v1.AcknowledgeAlertRequest) (*v1.AcknowledgeAlertResponse, error) {
+	err := s.ser.AcknowledgeAlert(ctx, &domain.AcknowledgeAlertRequest{
+		StudentId: req.StudentId,
+		RoomId:    req.RoomId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.AcknowledgeAlertResponse{}, nil
+}
+
+func (s *ElecpriceServiceServer) SnoozeStandard(ctx context.Context, req *v1.SnoozeStandardRequest) (*v1.SnoozeStandardResponse, error) {
+	err := s.ser.SnoozeStandard(ctx, &domain.SnoozeStandardRequest{
+		StudentId: req.StudentId,
+		RoomId:    req.RoomId,
+		Until:     req.Until,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.SnoozeStandardResponse{}, nil
+}
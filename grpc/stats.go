@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) GetBuildingStats(ctx context.Context, req *v1.GetBuildingStatsRequest) (*v1.GetBuildingStatsResponse, error) {
+	res, err := s.statsSer.GetBuildingStats(ctx, &domain.GetBuildingStatsRequest{
+		Area:           req.Area,
+		ArchitectureId: req.ArchitectureId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.GetBuildingStatsResponse{Stats: toV1BuildingStats(res)}, nil
+}
+
+func (s *ElecpriceServiceServer) JoinLeaderboard(ctx context.Context, req *v1.JoinLeaderboardRequest) (*v1.JoinLeaderboardResponse, error) {
+	err := s.statsSer.JoinLeaderboard(ctx, &domain.JoinLeaderboardRequest{
+		StudentId:      req.StudentId,
+		RoomId:         req.RoomId,
+		Area:           req.Area,
+		ArchitectureId: req.ArchitectureId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.JoinLeaderboardResponse{}, nil
+}
+
+func (s *ElecpriceServiceServer) LeaveLeaderboard(ctx context.Context, req *v1.LeaveLeaderboardRequest) (*v1.LeaveLeaderboardResponse, error) {
+	err := s.statsSer.LeaveLeaderboard(ctx, &domain.LeaveLeaderboardRequest{
+		StudentId: req.StudentId,
+		RoomId:    req.RoomId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.LeaveLeaderboardResponse{}, nil
+}
+
+func (s *ElecpriceServiceServer) GetLeaderboard(ctx context.Context, req *v1.GetLeaderboardRequest) (*v1.GetLeaderboardResponse, error) {
+	res, err := s.statsSer.GetLeaderboard(ctx, &domain.GetLeaderboardRequest{
+		ArchitectureId: req.ArchitectureId,
+		Limit:          int(req.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp v1.GetLeaderboardResponse
+	for _, e := range res {
+		resp.Entries = append(resp.Entries, &v1.LeaderboardEntry{
+			Rank:       e.Rank,
+			RoomId:     e.RoomId,
+			RoomName:   e.RoomName,
+			DailyUsage: e.DailyUsage,
+		})
+	}
+	return &resp, nil
+}
+
+func toV1BuildingStats(stats *domain.BuildingStats) *v1.BuildingStats {
+	res := &v1.BuildingStats{
+		Area:             stats.Area,
+		ArchitectureId:   stats.ArchitectureId,
+		ArchitectureName: stats.ArchitectureName,
+		Rooms:            stats.Rooms,
+		RoomsWithData:    stats.RoomsWithData,
+		MeanDailyUsage:   stats.MeanDailyUsage,
+		MedianDailyUsage: stats.MedianDailyUsage,
+		NearZero:         stats.NearZero,
+	}
+	for _, b := range stats.Balances {
+		res.Balances = append(res.Balances, &v1.BalanceBucket{Label: b.Label, Rooms: b.Rooms})
+	}
+	for _, a := range stats.Architectures {
+		res.Architectures = append(res.Architectures, toV1BuildingStats(a))
+	}
+	return res
+}
@@ -0,0 +1,21 @@
+package grpc
+
+import (
+	"context"
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) PreviewTemplate(ctx context.Context, req *v1.PreviewTemplateRequest) (*v1.PreviewTemplateResponse, error) {
+	res, err := s.templateSer.PreviewTemplate(ctx, &domain.PreviewTemplateRequest{
+		Severity: req.Severity,
+		Locale:   req.Locale,
+		Title:    req.Title,
+		Content:  req.Content,
+		RoomId:   req.RoomId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.PreviewTemplateResponse{Title: res.Title, Content: res.Content}, nil
+}
@@ -0,0 +1,24 @@
+package grpc
+
+import (
+	v1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+func (s *ElecpriceServiceServer) WatchPrice(req *v1.WatchPriceRequest, stream v1.ElecpriceService_WatchPriceServer) error {
+	return s.watchSer.WatchPrice(stream.Context(), &domain.WatchPriceRequest{
+		StudentId: req.StudentId,
+		RoomIds:   req.RoomIds,
+	}, func(e *domain.WatchPriceEvent) error {
+		if e.Heartbeat {
+			return stream.Send(&v1.WatchPriceResponse{Heartbeat: true})
+		}
+		return stream.Send(&v1.WatchPriceResponse{Reading: &v1.Reading{
+			RoomId:            e.Reading.RoomId,
+			RemainMoney:       e.Reading.RemainMoney,
+			YesterdayUseValue: e.Reading.YesterdayUseValue,
+			YesterdayUseMoney: e.Reading.YesterdayUseMoney,
+			ReadAt:            e.Reading.ReadAt,
+		}})
+	})
+}
@@ -1,8 +1,10 @@
 package ioc
 
 import (
+	"fmt"
 	"github.com/asynccnu/be-elecprice/pkg/logger"
 	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/glebarez/sqlite"
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -11,13 +13,36 @@ import (
 
 func InitDB(l logger.Logger) *gorm.DB {
 	type Config struct {
-		DSN string `yaml:"dsn"`
+		Driver      string `yaml:"driver"`      // mysql 或 sqlite,默认 mysql
+		DSN         string `yaml:"dsn"`         // sqlite 时为数据库文件路径, :memory: 表示内存数据库
+		AutoMigrate bool   `yaml:"autoMigrate"` // 启动时自动建表,仅用于开发环境,其他环境使用 migrate 子命令
 	}
 	var cfg Config
-	if err := viper.UnmarshalKey("mysql", &cfg); err != nil {
+	// 兼容旧配置中的 mysql 节点
+	key := "db"
+	if !viper.IsSet(key) {
+		key = "mysql"
+	}
+	if err := viper.UnmarshalKey(key, &cfg); err != nil {
 		panic(err)
 	}
-	db, err := gorm.Open(mysql.Open(cfg.DSN), &gorm.Config{
+
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case "", "mysql":
+		dialector = mysql.Open(cfg.DSN)
+	case "sqlite":
+		dsn := cfg.DSN
+		// 每个连接都会打开一个新的内存数据库,需要共享缓存
+		if dsn == ":memory:" {
+			dsn = "file::memory:?cache=shared"
+		}
+		dialector = sqlite.Open(dsn)
+	default:
+		panic(fmt.Errorf("不支持的数据库驱动: %s", cfg.Driver))
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: glogger.New(gormLoggerFunc(l.Debug), glogger.Config{
 			SlowThreshold: 0,
 			LogLevel:      glogger.Info, // 以Debug模式打印所有Info级别能产生的gorm日志
@@ -26,9 +51,21 @@ func InitDB(l logger.Logger) *gorm.DB {
 	if err != nil {
 		panic(err)
 	}
-	err = dao.InitTables(db)
-	if err != nil {
-		panic(err)
+
+	if cfg.Driver == "sqlite" {
+		// sqlite 同一时间只允许一个写入,限制为单连接避免 database is locked
+		sqlDB, err := db.DB()
+		if err != nil {
+			panic(err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if cfg.AutoMigrate {
+		err = dao.InitTables(db)
+		if err != nil {
+			panic(err)
+		}
 	}
 	return db
 }
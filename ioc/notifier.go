@@ -0,0 +1,47 @@
+package ioc
+
+import (
+	feedv1 "github.com/asynccnu/be-api/gen/proto/feed/v1"
+	"github.com/asynccnu/be-elecprice/notifier"
+	"github.com/spf13/viper"
+)
+
+func InitNotifier(feedClient feedv1.FeedServiceClient) *notifier.Dispatcher {
+	type Config struct {
+		DefaultChannel string `yaml:"defaultChannel"` // 学生没有选择渠道或渠道未启用时使用
+		Feed           struct {
+			Retry notifier.RetryConfig `yaml:"retry"`
+		} `yaml:"feed"`
+		Webhook struct {
+			Enabled                bool                 `yaml:"enabled"`
+			Retry                  notifier.RetryConfig `yaml:"retry"`
+			notifier.WebhookConfig `yaml:",inline" mapstructure:",squash"`
+		} `yaml:"webhook"`
+		SMTP struct {
+			Enabled             bool                 `yaml:"enabled"`
+			Retry               notifier.RetryConfig `yaml:"retry"`
+			notifier.SMTPConfig `yaml:",inline" mapstructure:",squash"`
+		} `yaml:"smtp"`
+		Fake struct {
+			Enabled bool `yaml:"enabled"`
+		} `yaml:"fake"`
+	}
+	cfg := Config{DefaultChannel: notifier.ChannelFeed}
+	if err := viper.UnmarshalKey("notifier", &cfg); err != nil {
+		panic(err)
+	}
+
+	notifiers := map[string]notifier.Notifier{
+		notifier.ChannelFeed: notifier.WithRetry(notifier.NewFeedNotifier(feedClient), cfg.Feed.Retry),
+	}
+	if cfg.Webhook.Enabled {
+		notifiers[notifier.ChannelWebhook] = notifier.WithRetry(notifier.NewWebhookNotifier(cfg.Webhook.WebhookConfig), cfg.Webhook.Retry)
+	}
+	if cfg.SMTP.Enabled {
+		notifiers[notifier.ChannelEmail] = notifier.WithRetry(notifier.NewSMTPNotifier(cfg.SMTP.SMTPConfig), cfg.SMTP.Retry)
+	}
+	if cfg.Fake.Enabled {
+		notifiers[notifier.ChannelFake] = notifier.NewFakeNotifier()
+	}
+	return notifier.NewDispatcher(notifiers, cfg.DefaultChannel)
+}
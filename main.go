@@ -1,17 +1,38 @@
 package main
 
 import (
+	"context"
 	"github.com/asynccnu/be-elecprice/cron"
 	"github.com/asynccnu/be-elecprice/pkg/grpcx"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/service"
+	"io"
+	"os/signal"
+	"syscall"
+	"time"
 	//
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gorm.io/gorm"
 )
 
 func main() {
 	initViper()
+
+	// ./app migrate up|down|status 执行数据库迁移后退出
+	if args := pflag.Args(); len(args) > 0 && args[0] == "migrate" {
+		runMigrate(InitMigrator(), args[1:])
+		return
+	}
+
 	app := InitApp()
-	app.Start()
+
+	// 退出信号只在这里处理,grpc 服务不再单独监听
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	app.Start(ctx)
 }
 
 func initViper() {
@@ -27,27 +48,107 @@ func initViper() {
 }
 
 type App struct {
-	server grpcx.Server
-	crons  []cron.Cron
+	server     grpcx.Server
+	crons      []cron.Cron
+	jobService service.JobService
+	// watchService 的推送连接不会自己结束,关闭服务前需要先结束它们
+	watchService service.WatchService
+	etcdClient   *clientv3.Client
+	db           *gorm.DB
+	redis        redis.Cmdable
+	l            logger.Logger
 }
 
 func NewApp(server grpcx.Server,
-	crons []cron.Cron) App {
+	crons []cron.Cron,
+	jobService service.JobService,
+	watchService service.WatchService,
+	etcdClient *clientv3.Client,
+	db *gorm.DB,
+	redis redis.Cmdable,
+	l logger.Logger) App {
 	return App{
-		server: server,
-		crons:  crons,
+		server:       server,
+		crons:        crons,
+		jobService:   jobService,
+		watchService: watchService,
+		etcdClient:   etcdClient,
+		db:           db,
+		redis:        redis,
+		l:            l,
 	}
 }
 
-func (a *App) Start() {
+// Start 启动服务并阻塞,直到 ctx 结束或服务异常退出后优雅关闭
+func (a *App) Start(ctx context.Context) {
+	// 收到退出信号后执行中的任务还要继续,等待超时后才取消
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+	for _, c := range a.crons {
+		c.StartCronTask(jobCtx)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- a.server.Serve()
+	}()
+
+	select {
+	case <-ctx.Done():
+		a.l.Info("收到退出信号,开始关闭服务")
+	case err := <-errChan:
+		if err != nil {
+			a.l.Error("服务异常退出", logger.Error(err))
+		}
+	}
+
+	a.shutdown(cancelJobs)
+}
+
+// shutdown 按顺序关闭: 结束实时推送 -> 停止接收请求并注销 -> 停止定时任务 -> 等待执行中的任务 -> 关闭 etcd、数据库和 redis
+func (a *App) shutdown(cancelJobs context.CancelFunc) {
+	type Config struct {
+		Timeout int64 `yaml:"timeout"` // 等待处理中的请求和执行中任务的最长时间,单位秒
+	}
+	var cfg Config
+	if err := viper.UnmarshalKey("shutdown", &cfg); err != nil || cfg.Timeout <= 0 {
+		cfg.Timeout = 30
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout)*time.Second)
+	defer cancel()
+	context.AfterFunc(ctx, cancelJobs)
+
+	a.watchService.Close()
+	if err := a.server.Close(ctx); err != nil {
+		a.l.Error("关闭grpc服务失败", logger.Error(err))
+	}
 
 	for _, c := range a.crons {
-		c.StartCronTask()
+		c.StopCronTask()
+	}
+
+	if err := a.jobService.Close(ctx); err != nil {
+		a.l.Error("等待任务结束超时,已取消执行中的任务", logger.Error(err))
 	}
 
-	err := a.server.Serve()
+	if err := a.etcdClient.Close(); err != nil {
+		a.l.Error("关闭etcd客户端失败", logger.Error(err))
+	}
+
+	sqlDB, err := a.db.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
 	if err != nil {
-		return
+		a.l.Error("关闭数据库失败", logger.Error(err))
+	}
+
+	if c, ok := a.redis.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			a.l.Error("关闭redis失败", logger.Error(err))
+		}
 	}
 
+	a.l.Info("服务已关闭")
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/repository/migration"
+	"os"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = `用法:
+  app migrate up [version]  执行迁移到指定版本,不指定时执行到最新版本
+  app migrate down [steps]  回滚最近的 steps 个迁移,默认 1
+  app migrate status        查看迁移状态`
+
+func runMigrate(m *migration.Migrator, args []string) {
+	if len(args) == 0 {
+		exitMigrate(fmt.Errorf("缺少迁移命令"))
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		var target int64
+		if len(args) > 1 {
+			v, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				exitMigrate(fmt.Errorf("非法的版本号: %s", args[1]))
+			}
+			target = v
+		}
+		n, err := m.Up(ctx, target)
+		if err != nil {
+			exitMigrate(err)
+		}
+		fmt.Printf("执行了 %d 个迁移\n", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v <= 0 {
+				exitMigrate(fmt.Errorf("非法的回滚数量: %s", args[1]))
+			}
+			steps = v
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			exitMigrate(err)
+		}
+		fmt.Printf("回滚了 %d 个迁移\n", n)
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			exitMigrate(err)
+		}
+		for _, s := range statuses {
+			appliedAt := "未执行"
+			if s.Applied {
+				appliedAt = time.Unix(s.AppliedAt, 0).Format(time.DateTime)
+			}
+			fmt.Printf("%-6d %-45s %s\n", s.Version, s.Name, appliedAt)
+		}
+
+	default:
+		exitMigrate(fmt.Errorf("未知的迁移命令: %s", args[0]))
+	}
+}
+
+func exitMigrate(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	fmt.Fprintln(os.Stderr, migrateUsage)
+	os.Exit(1)
+}
@@ -0,0 +1,43 @@
+package notifier
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"sync"
+)
+
+// FakeNotifier 只在内存中记录提醒,用于本地开发和测试,不会真正发送
+type FakeNotifier struct {
+	mu   sync.Mutex
+	sent []FakeMessage
+	// Err 不为空时每次发送都返回该错误,用于模拟发送失败
+	Err error
+}
+
+type FakeMessage struct {
+	Address string
+	Event   domain.FeedEvent
+}
+
+func NewFakeNotifier() *FakeNotifier {
+	return &FakeNotifier{}
+}
+
+func (n *FakeNotifier) Notify(ctx context.Context, address string, event *domain.FeedEvent) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.Err != nil {
+		return "", n.Err
+	}
+	n.sent = append(n.sent, FakeMessage{Address: address, Event: *event})
+	return "fake", nil
+}
+
+// Sent 返回已经记录的提醒
+func (n *FakeNotifier) Sent() []FakeMessage {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	res := make([]FakeMessage, len(n.sent))
+	copy(res, n.sent)
+	return res
+}
@@ -0,0 +1,31 @@
+package notifier
+
+import (
+	"context"
+	feedv1 "github.com/asynccnu/be-api/gen/proto/feed/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+// FeedNotifier 通过 feed 服务推送到 App,按学号推送,不需要地址
+type FeedNotifier struct {
+	client feedv1.FeedServiceClient
+}
+
+func NewFeedNotifier(client feedv1.FeedServiceClient) *FeedNotifier {
+	return &FeedNotifier{client: client}
+}
+
+func (n *FeedNotifier) Notify(ctx context.Context, address string, event *domain.FeedEvent) (string, error) {
+	resp, err := n.client.PublicFeedEvent(ctx, &feedv1.PublicFeedEventReq{
+		StudentId: event.StudentId,
+		Event: &feedv1.FeedEvent{
+			Type:    event.Type,
+			Title:   event.Title,
+			Content: event.Content,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.String(), nil
+}
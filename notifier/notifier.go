@@ -0,0 +1,50 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/domain"
+)
+
+// 支持的提醒渠道
+const (
+	ChannelFeed    = "feed"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+	ChannelFake    = "fake"
+)
+
+// Notifier 一种提醒渠道,address 为学生在该渠道的地址,如 webhook 地址或邮箱,
+// 返回渠道的响应,用于记录提醒历史
+type Notifier interface {
+	Notify(ctx context.Context, address string, event *domain.FeedEvent) (string, error)
+}
+
+// Dispatcher 按渠道选择 Notifier,未启用的渠道退回默认渠道
+type Dispatcher struct {
+	notifiers      map[string]Notifier
+	defaultChannel string
+}
+
+func NewDispatcher(notifiers map[string]Notifier, defaultChannel string) *Dispatcher {
+	if _, ok := notifiers[defaultChannel]; !ok {
+		panic(fmt.Errorf("默认提醒渠道 %s 没有启用", defaultChannel))
+	}
+	return &Dispatcher{notifiers: notifiers, defaultChannel: defaultChannel}
+}
+
+// Enabled 判断渠道是否启用
+func (d *Dispatcher) Enabled(channel string) bool {
+	_, ok := d.notifiers[channel]
+	return ok
+}
+
+// Notify 通过学生选择的渠道发送提醒,返回实际使用的渠道和渠道的响应
+func (d *Dispatcher) Notify(ctx context.Context, channel string, address string, event *domain.FeedEvent) (string, string, error) {
+	n, ok := d.notifiers[channel]
+	if !ok {
+		channel, n = d.defaultChannel, d.notifiers[d.defaultChannel]
+	}
+	resp, err := n.Notify(ctx, address, event)
+	return channel, resp, err
+}
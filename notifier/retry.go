@@ -0,0 +1,40 @@
+package notifier
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"time"
+)
+
+type RetryConfig struct {
+	Retries int   `yaml:"retries"` // 失败后的重试次数
+	Backoff int64 `yaml:"backoff"` // 第一次重试前的等待时间,之后每次翻倍,单位毫秒
+}
+
+type retryNotifier struct {
+	n   Notifier
+	cfg RetryConfig
+}
+
+// WithRetry 为 Notifier 增加失败重试
+func WithRetry(n Notifier, cfg RetryConfig) Notifier {
+	if cfg.Retries <= 0 {
+		return n
+	}
+	return &retryNotifier{n: n, cfg: cfg}
+}
+
+func (r *retryNotifier) Notify(ctx context.Context, address string, event *domain.FeedEvent) (string, error) {
+	backoff := time.Duration(r.cfg.Backoff) * time.Millisecond
+	resp, err := r.n.Notify(ctx, address, event)
+	for i := 0; err != nil && i < r.cfg.Retries; i++ {
+		select {
+		case <-ctx.Done():
+			return resp, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		resp, err = r.n.Notify(ctx, address, event)
+	}
+	return resp, err
+}
@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/domain"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// maxResponseSize 读取的 webhook 响应的最大长度,响应内容不会被记录
+const maxResponseSize = 1024
+
+// sharedAddressSpace 运营商级 NAT 使用的地址段,不属于 IsPrivate 的范围
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`     // 学生没有设置地址时使用的地址,如 QQ/微信机器人的转发服务
+	Headers map[string]string `yaml:"headers"` // 附加的请求头,如鉴权 token,只发送给 URL
+	Timeout int64             `yaml:"timeout"` // 请求超时,单位秒
+}
+
+// WebhookNotifier 以 JSON 格式 POST 提醒到 webhook 地址
+type WebhookNotifier struct {
+	// client 只用于配置中的地址,该地址可以在内网
+	client *http.Client
+	// publicClient 用于学生设置的地址,连接时拒绝内网、本机和云服务元数据地址
+	publicClient *http.Client
+	cfg          WebhookConfig
+}
+
+func NewWebhookNotifier(cfg WebhookConfig) *WebhookNotifier {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// 在 DNS 解析之后检查实际连接的地址,重定向和 DNS 重绑定同样会被拦截
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("webhook 地址 %s 不是公网地址", host)
+			}
+			return nil
+		},
+	}
+	return &WebhookNotifier{
+		client: &http.Client{Timeout: timeout},
+		publicClient: &http.Client{
+			Timeout: timeout,
+			// 不使用代理,否则检查的是代理的地址
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+		cfg: cfg,
+	}
+}
+
+type webhookPayload struct {
+	StudentId string `json:"studentId"`
+	RoomId    string `json:"roomId"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+}
+
+// Notify 返回的响应只包含状态码,webhook 返回的内容可能来自任意地址,不能记录或展示给学生
+func (n *WebhookNotifier) Notify(ctx context.Context, address string, event *domain.FeedEvent) (string, error) {
+	target, client := address, n.publicClient
+	if target == "" || target == n.cfg.URL {
+		target, client = n.cfg.URL, n.client
+	}
+	if target == "" {
+		return "", errors.New("没有设置 webhook 地址")
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		StudentId: event.StudentId,
+		RoomId:    event.RoomId,
+		Type:      event.Type,
+		Title:     event.Title,
+		Content:   event.Content,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// 鉴权信息只发送给配置中的地址
+	if client == n.client {
+		for k, v := range n.cfg.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	// 读完响应以便复用连接
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	status := fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return status, fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return status, nil
+}
+
+// ValidateWebhookURL 检查学生设置的 webhook 地址,主机为 IP 时必须是公网地址
+// 域名在每次连接时解析后再检查
+func ValidateWebhookURL(address string) error {
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("webhook 地址不合法: %s", address)
+	}
+	if u.Hostname() == "localhost" {
+		return fmt.Errorf("webhook 地址不能是本机: %s", address)
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("webhook 地址不是公网地址: %s", address)
+	}
+	return nil
+}
+
+// isPublicIP 判断是否为公网地址,本机、内网、链路本地(包括云服务元数据 169.254.169.254)和组播地址都不是
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
@@ -71,7 +71,7 @@ func (s *GRPCServer) register(ctx context.Context, port string) error {
 		endpoints.Endpoint{Addr: addr}, clientv3.WithLease(leaseResp.ID))
 }
 
-func (s *GRPCServer) Close() error {
+func (s *GRPCServer) Close(ctx context.Context) error {
 	s.cancel()
 	if s.etcdManager != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -85,6 +85,16 @@ func (s *GRPCServer) Close() error {
 	if err != nil {
 		return err
 	}
-	s.Server.GracefulStop()
+	done := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Server.Stop()
+		<-done
+	}
 	return nil
 }
@@ -1,46 +1,101 @@
 package grpcx
 
 import (
+	"context"
 	"github.com/asynccnu/be-elecprice/pkg/logger"
 	etcd "github.com/go-kratos/kratos/contrib/registry/etcd/v2"
-	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/registry"
 	"github.com/go-kratos/kratos/v2/transport/grpc"
 	etcdv3 "go.etcd.io/etcd/client/v3"
 	"strconv"
+	"sync"
 	"time"
 )
 
+// registerTimeout 注册和注销服务的超时时间
+const registerTimeout = 10 * time.Second
+
+// KratosServer 使用 kratos 的 grpc 服务和 etcd 注册中心
+// 不使用 kratos.App,它会自己监听退出信号,退出信号统一由 main 处理
 type KratosServer struct {
 	*grpc.Server
 	Name       string
 	Weight     int
 	EtcdTTL    time.Duration
 	EtcdClient *etcdv3.Client
-	stop       func() error
 	L          logger.Logger
+
+	mu        sync.Mutex
+	registrar *etcd.Registry
+	instance  *registry.ServiceInstance
+	done      chan struct{}
 }
 
 // Serve 启动服务器并且阻塞
 func (s *KratosServer) Serve() error {
+	endpoint, err := s.Server.Endpoint()
+	if err != nil {
+		return err
+	}
 	r := etcd.New(s.EtcdClient, etcd.RegisterTTL(s.EtcdTTL))
-	app := kratos.New(
-		kratos.Metadata(map[string]string{
+	instance := &registry.ServiceInstance{
+		ID:   s.Name + "-" + endpoint.Host,
+		Name: s.Name,
+		Metadata: map[string]string{
 			"weight": strconv.Itoa(s.Weight),
-		}),
-		kratos.Name(s.Name),
-		kratos.Server(
-			s.Server,
-		),
-		kratos.Registrar(r),
-	)
-	s.stop = app.Stop
-	return app.Run()
-}
+		},
+		Endpoints: []string{endpoint.String()},
+	}
 
-func (s *KratosServer) Close() error {
-	err := s.EtcdClient.Close()
-	if err != nil {
+	done := make(chan struct{})
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(done)
+		errChan <- s.Server.Start(context.Background())
+	}()
+
+	// 已经开始监听,再注册服务
+	ctx, cancel := context.WithTimeout(context.Background(), registerTimeout)
+	defer cancel()
+	if err := r.Register(ctx, instance); err != nil {
+		s.Server.Server.Stop()
+		<-done
 		return err
 	}
-	return s.stop()
+	s.mu.Lock()
+	s.registrar, s.instance, s.done = r, instance, done
+	s.mu.Unlock()
+
+	return <-errChan
+}
+
+// Close 从注册中心注销并停止接收新请求,等待处理中的请求结束后返回,ctx 结束时强制关闭剩余的连接
+// etcd 客户端还被服务发现使用,由调用方在最后关闭
+func (s *KratosServer) Close(ctx context.Context) error {
+	s.mu.Lock()
+	r, instance, done := s.registrar, s.instance, s.done
+	s.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, registerTimeout)
+	err := r.Deregister(dctx, instance)
+	cancel()
+
+	// Serve 在监听关闭时就会返回,GracefulStop 返回时处理中的请求才全部结束
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		_ = s.Server.Stop(ctx)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.L.Warn("等待请求结束超时,强制关闭连接")
+		s.Server.Server.Stop()
+		<-stopped
+	}
+	<-done
+	return err
 }
@@ -1,6 +1,9 @@
 package grpcx
 
+import "context"
+
 type Server interface {
 	Serve() error
-	Close() error
+	// Close 停止服务,等待处理中的请求结束,ctx 结束时强制关闭剩余的连接
+	Close(ctx context.Context) error
 }
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+)
+
+// AlertHistoryRepository 发给学生的提醒历史
+type AlertHistoryRepository interface {
+	// Create 保存提醒记录并回填 ID
+	Create(ctx context.Context, r *domain.AlertRecord) error
+	UpdateStatus(ctx context.Context, id int64, channel string, status string, feedResponse string, deliveredAt int64) error
+	ListByStudent(ctx context.Context, studentId string, offset int, limit int) ([]*domain.AlertRecord, error)
+}
+
+type alertHistoryRepository struct {
+	dao dao.AlertHistoryDAO
+}
+
+func NewAlertHistoryRepository(dao dao.AlertHistoryDAO) AlertHistoryRepository {
+	return &alertHistoryRepository{dao: dao}
+}
+
+func (r *alertHistoryRepository) Create(ctx context.Context, record *domain.AlertRecord) error {
+	h := &model.AlertHistory{
+		StudentID:    record.StudentId,
+		RoomID:       record.RoomId,
+		RoomName:     record.RoomName,
+		Remain:       record.Remain,
+		Limit:        record.Limit,
+		Severity:     record.Severity,
+		Title:        record.Title,
+		Content:      record.Content,
+		Status:       record.Status,
+		Channel:      record.Channel,
+		FeedResponse: record.FeedResponse,
+		DeliveredAt:  record.DeliveredAt,
+	}
+	if err := r.dao.Create(ctx, h); err != nil {
+		return err
+	}
+	record.ID = h.ID
+	record.CreatedAt = h.CreatedAt
+	return nil
+}
+
+func (r *alertHistoryRepository) UpdateStatus(ctx context.Context, id int64, channel string, status string, feedResponse string, deliveredAt int64) error {
+	return r.dao.UpdateStatus(ctx, id, channel, status, feedResponse, deliveredAt)
+}
+
+func (r *alertHistoryRepository) ListByStudent(ctx context.Context, studentId string, offset int, limit int) ([]*domain.AlertRecord, error) {
+	hs, err := r.dao.ListByStudent(ctx, studentId, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*domain.AlertRecord, 0, len(hs))
+	for _, h := range hs {
+		res = append(res, &domain.AlertRecord{
+			ID:           h.ID,
+			StudentId:    h.StudentID,
+			RoomId:       h.RoomID,
+			RoomName:     h.RoomName,
+			Remain:       h.Remain,
+			Limit:        h.Limit,
+			Severity:     h.Severity,
+			Title:        h.Title,
+			Content:      h.Content,
+			Status:       h.Status,
+			Channel:      h.Channel,
+			FeedResponse: h.FeedResponse,
+			CreatedAt:    h.CreatedAt,
+			DeliveredAt:  h.DeliveredAt,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// CatalogCache 缓存区域下的楼栋和楼层下的房间,这些数据几乎不会变化
+type CatalogCache interface {
+	GetArchitecture(ctx context.Context, area string) (domain.ResultArchitectureInfo, error)
+	SetArchitecture(ctx context.Context, area string, info domain.ResultArchitectureInfo) error
+	GetRoomInfo(ctx context.Context, archiID string, floor string) (map[string]string, error)
+	SetRoomInfo(ctx context.Context, archiID string, floor string, rooms map[string]string) error
+	GetMeterID(ctx context.Context, roomID string) (string, error)
+	SetMeterID(ctx context.Context, roomID string, meterID string) error
+}
+
+type redisCatalogCache struct {
+	cmd        redis.Cmdable
+	expiration time.Duration
+}
+
+func NewRedisCatalogCache(cmd redis.Cmdable) CatalogCache {
+	return &redisCatalogCache{
+		cmd:        cmd,
+		expiration: 24 * time.Hour,
+	}
+}
+
+func (c *redisCatalogCache) GetArchitecture(ctx context.Context, area string) (domain.ResultArchitectureInfo, error) {
+	var info domain.ResultArchitectureInfo
+	err := c.get(ctx, fmt.Sprintf("elecprice:catalog:architecture:%s", area), &info)
+	return info, err
+}
+
+func (c *redisCatalogCache) SetArchitecture(ctx context.Context, area string, info domain.ResultArchitectureInfo) error {
+	return c.set(ctx, fmt.Sprintf("elecprice:catalog:architecture:%s", area), info)
+}
+
+func (c *redisCatalogCache) GetRoomInfo(ctx context.Context, archiID string, floor string) (map[string]string, error) {
+	var rooms map[string]string
+	err := c.get(ctx, fmt.Sprintf("elecprice:catalog:room:%s:%s", archiID, floor), &rooms)
+	return rooms, err
+}
+
+func (c *redisCatalogCache) SetRoomInfo(ctx context.Context, archiID string, floor string, rooms map[string]string) error {
+	return c.set(ctx, fmt.Sprintf("elecprice:catalog:room:%s:%s", archiID, floor), rooms)
+}
+
+func (c *redisCatalogCache) GetMeterID(ctx context.Context, roomID string) (string, error) {
+	var meterID string
+	err := c.get(ctx, fmt.Sprintf("elecprice:catalog:meter:%s", roomID), &meterID)
+	return meterID, err
+}
+
+func (c *redisCatalogCache) SetMeterID(ctx context.Context, roomID string, meterID string) error {
+	return c.set(ctx, fmt.Sprintf("elecprice:catalog:meter:%s", roomID), meterID)
+}
+
+func (c *redisCatalogCache) get(ctx context.Context, key string, val any) error {
+	data, err := c.cmd.Get(ctx, key).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, val)
+}
+
+func (c *redisCatalogCache) set(ctx context.Context, key string, val any) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, key, data, c.expiration).Err()
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/redis/go-redis/v9"
+	"sync"
+)
+
+// ReadingBroker 广播新的电费读数,通过 Redis 发布订阅让其他副本上的订阅者也能收到
+type ReadingBroker interface {
+	// Publish 广播读数,Redis 不可用时只通知本副本的订阅者并返回错误
+	Publish(ctx context.Context, r *domain.Reading) error
+	// Subscribe 订阅房间的新读数,调用返回的函数取消订阅,之后通道会被关闭
+	Subscribe(roomIds []string) (<-chan *domain.Reading, func())
+}
+
+const readingChannel = "elecprice:reading"
+
+// readingBufferSize 订阅者处理不过来时丢弃新的读数,之后的读数会带上最新的余额
+const readingBufferSize = 16
+
+type readingSubscriber struct {
+	ch chan *domain.Reading
+}
+
+type redisReadingBroker struct {
+	// client 为 nil 时只在本副本内广播
+	client redis.UniversalClient
+	once   sync.Once
+
+	mu   sync.RWMutex
+	subs map[string]map[*readingSubscriber]struct{}
+}
+
+func NewRedisReadingBroker(cmd redis.Cmdable) ReadingBroker {
+	b := &redisReadingBroker{subs: make(map[string]map[*readingSubscriber]struct{})}
+	// 订阅需要完整的客户端,Cmdable 只提供发布
+	if client, ok := cmd.(redis.UniversalClient); ok {
+		b.client = client
+	}
+	return b
+}
+
+func (b *redisReadingBroker) Publish(ctx context.Context, r *domain.Reading) error {
+	if b.client == nil {
+		b.dispatch(r)
+		return nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	// 发布成功时本副本会从 Redis 收到同一条消息,不需要再分发
+	if err := b.client.Publish(ctx, readingChannel, data).Err(); err != nil {
+		b.dispatch(r)
+		return err
+	}
+	return nil
+}
+
+func (b *redisReadingBroker) Subscribe(roomIds []string) (<-chan *domain.Reading, func()) {
+	b.once.Do(b.listen)
+
+	sub := &readingSubscriber{ch: make(chan *domain.Reading, readingBufferSize)}
+	b.mu.Lock()
+	for _, roomId := range roomIds {
+		if b.subs[roomId] == nil {
+			b.subs[roomId] = make(map[*readingSubscriber]struct{})
+		}
+		b.subs[roomId][sub] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	var cancelOnce sync.Once
+	return sub.ch, func() {
+		cancelOnce.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			for _, roomId := range roomIds {
+				delete(b.subs[roomId], sub)
+				if len(b.subs[roomId]) == 0 {
+					delete(b.subs, roomId)
+				}
+			}
+			close(sub.ch)
+		})
+	}
+}
+
+// listen 第一次有人订阅时开始接收 Redis 上的读数,连接断开后客户端会自动重连
+func (b *redisReadingBroker) listen() {
+	if b.client == nil {
+		return
+	}
+	ps := b.client.Subscribe(context.Background(), readingChannel)
+	go func() {
+		for msg := range ps.Channel() {
+			var r domain.Reading
+			if err := json.Unmarshal([]byte(msg.Payload), &r); err != nil {
+				continue
+			}
+			b.dispatch(&r)
+		}
+	}()
+}
+
+func (b *redisReadingBroker) dispatch(r *domain.Reading) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs[r.RoomId] {
+		select {
+		case sub.ch <- r:
+		default:
+		}
+	}
+}
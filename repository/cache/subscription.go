@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// ErrKeyNotExist 缓存未命中
+var ErrKeyNotExist = redis.Nil
+
+// SubscriptionCache 缓存学生的订阅列表
+type SubscriptionCache interface {
+	Get(ctx context.Context, studentId string) ([]*domain.Subscription, error)
+	Set(ctx context.Context, studentId string, subs []*domain.Subscription) error
+	Del(ctx context.Context, studentIds ...string) error
+}
+
+type redisSubscriptionCache struct {
+	cmd        redis.Cmdable
+	expiration time.Duration
+}
+
+func NewRedisSubscriptionCache(cmd redis.Cmdable) SubscriptionCache {
+	return &redisSubscriptionCache{
+		cmd:        cmd,
+		expiration: 30 * time.Minute,
+	}
+}
+
+func (c *redisSubscriptionCache) Get(ctx context.Context, studentId string) ([]*domain.Subscription, error) {
+	data, err := c.cmd.Get(ctx, c.key(studentId)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var subs []*domain.Subscription
+	err = json.Unmarshal(data, &subs)
+	return subs, err
+}
+
+func (c *redisSubscriptionCache) Set(ctx context.Context, studentId string, subs []*domain.Subscription) error {
+	data, err := json.Marshal(subs)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, c.key(studentId), data, c.expiration).Err()
+}
+
+func (c *redisSubscriptionCache) Del(ctx context.Context, studentIds ...string) error {
+	if len(studentIds) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(studentIds))
+	for _, id := range studentIds {
+		keys = append(keys, c.key(id))
+	}
+	return c.cmd.Del(ctx, keys...).Err()
+}
+
+func (c *redisSubscriptionCache) key(studentId string) string {
+	return fmt.Sprintf("elecprice:subscription:%s", studentId)
+}
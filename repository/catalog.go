@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/cache"
+)
+
+// CatalogRepository 楼栋、房间和电表目录,数据来自学校的接口,这里只负责缓存
+type CatalogRepository interface {
+	FindArchitecture(ctx context.Context, area string) (domain.ResultArchitectureInfo, error)
+	SaveArchitecture(ctx context.Context, area string, info domain.ResultArchitectureInfo) error
+	FindRoomInfo(ctx context.Context, archiID string, floor string) (map[string]string, error)
+	SaveRoomInfo(ctx context.Context, archiID string, floor string, rooms map[string]string) error
+	FindMeterID(ctx context.Context, roomID string) (string, error)
+	SaveMeterID(ctx context.Context, roomID string, meterID string) error
+}
+
+type catalogRepository struct {
+	cache cache.CatalogCache
+}
+
+func NewCatalogRepository(cache cache.CatalogCache) CatalogRepository {
+	return &catalogRepository{cache: cache}
+}
+
+func (r *catalogRepository) FindArchitecture(ctx context.Context, area string) (domain.ResultArchitectureInfo, error) {
+	return r.cache.GetArchitecture(ctx, area)
+}
+
+func (r *catalogRepository) SaveArchitecture(ctx context.Context, area string, info domain.ResultArchitectureInfo) error {
+	return r.cache.SetArchitecture(ctx, area, info)
+}
+
+func (r *catalogRepository) FindRoomInfo(ctx context.Context, archiID string, floor string) (map[string]string, error) {
+	return r.cache.GetRoomInfo(ctx, archiID, floor)
+}
+
+func (r *catalogRepository) SaveRoomInfo(ctx context.Context, archiID string, floor string, rooms map[string]string) error {
+	return r.cache.SetRoomInfo(ctx, archiID, floor, rooms)
+}
+
+func (r *catalogRepository) FindMeterID(ctx context.Context, roomID string) (string, error) {
+	return r.cache.GetMeterID(ctx, roomID)
+}
+
+func (r *catalogRepository) SaveMeterID(ctx context.Context, roomID string, meterID string) error {
+	return r.cache.SetMeterID(ctx, roomID, meterID)
+}
@@ -0,0 +1,52 @@
+package dao
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+)
+
+// AlertHistoryStatusSuperseded 推迟的提醒被替换后提醒历史的状态
+const AlertHistoryStatusSuperseded = "superseded"
+
+// AlertHistoryDAO 提醒历史的数据库操作
+type AlertHistoryDAO interface {
+	Create(ctx context.Context, h *model.AlertHistory) error
+	UpdateStatus(ctx context.Context, id int64, channel string, status string, feedResponse string, deliveredAt int64) error
+	// ListByStudent 按时间倒序分页获取学生的提醒历史
+	ListByStudent(ctx context.Context, studentId string, offset int, limit int) ([]model.AlertHistory, error)
+}
+
+type alertHistoryDAO struct {
+	db *gorm.DB
+}
+
+// NewAlertHistoryDAO 构建提醒历史的数据库操作实例
+func NewAlertHistoryDAO(db *gorm.DB) AlertHistoryDAO {
+	return &alertHistoryDAO{db: db}
+}
+
+func (d *alertHistoryDAO) Create(ctx context.Context, h *model.AlertHistory) error {
+	return d.db.WithContext(ctx).Create(h).Error
+}
+
+func (d *alertHistoryDAO) UpdateStatus(ctx context.Context, id int64, channel string, status string, feedResponse string, deliveredAt int64) error {
+	return d.db.WithContext(ctx).
+		Model(&model.AlertHistory{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"channel": channel, "status": status, "feed_response": feedResponse, "delivered_at": deliveredAt}).Error
+}
+
+func (d *alertHistoryDAO) ListByStudent(ctx context.Context, studentId string, offset int, limit int) ([]model.AlertHistory, error) {
+	var hs []model.AlertHistory
+	err := d.db.WithContext(ctx).
+		Where("student_id = ?", studentId).
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&hs).Error
+	if err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"github.com/asynccnu/be-elecprice/repository/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 // ElecpriceDAO 数据库操作的集合
@@ -13,7 +15,20 @@ type ElecpriceDAO interface {
 	Delete(ctx context.Context, studentId string, roomId string) error
 	GetConfigsByCursor(ctx context.Context, lastID int64, limit int) ([]model.ElecpriceConfig, int64, error)
 	IsNotFoundError(err error) bool
-	Upsert(ctx context.Context, studentId string, roomId string, ec *model.ElecpriceConfig) error
+	// Upsert 写入配置并用 thresholds 替换该配置原有的阈值
+	Upsert(ctx context.Context, studentId string, roomId string, ec *model.ElecpriceConfig, thresholds []model.ElecpriceThreshold) error
+	// FindThresholds 批量获取配置的阈值,按配置和顺序排列
+	FindThresholds(ctx context.Context, configIDs []int64) ([]model.ElecpriceThreshold, error)
+	// UpdateThresholdAlertedAt 记录阈值最近一次发出提醒的时间
+	UpdateThresholdAlertedAt(ctx context.Context, ids []int64, at int64) error
+	// UpdateAnomalyAlertedAt 记录配置最近一次发出用电异常提醒的时间
+	UpdateAnomalyAlertedAt(ctx context.Context, ids []int64, at int64) error
+	// SoftDeleteByIDs 软删除指定的配置,返回删除的行数
+	SoftDeleteByIDs(ctx context.Context, ids []int64) (int64, error)
+	// CountDeletedBefore 统计在 before 之前被软删除的配置数
+	CountDeletedBefore(ctx context.Context, before time.Time) (int64, error)
+	// PurgeDeletedBefore 彻底删除在 before 之前被软删除的配置,返回删除的行数
+	PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error)
 }
 
 type elecpriceDAO struct {
@@ -70,11 +85,117 @@ func (d *elecpriceDAO) Delete(ctx context.Context, studentId string, roomId stri
 	return d.db.WithContext(ctx).Where("target_id = ? and student_id = ?", roomId, studentId).Delete(&model.ElecpriceConfig{}).Error
 }
 
-func (d *elecpriceDAO) Upsert(ctx context.Context, studentId string, roomId string, ec *model.ElecpriceConfig) error {
-	var old model.ElecpriceConfig
-	d.db.Where("student_id = ? and target_id = ?", studentId, roomId).First(&old)
-	if old.RoomName == ec.RoomName {
-		return d.db.Model(&old).Updates(ec).Error
+// Upsert 依赖 (student_id, target_id) 唯一索引,使用 INSERT ... ON DUPLICATE KEY UPDATE 原子地写入
+// 已取消(软删除)的订阅会被恢复
+func (d *elecpriceDAO) Upsert(ctx context.Context, studentId string, roomId string, ec *model.ElecpriceConfig, thresholds []model.ElecpriceThreshold) error {
+	ec.StudentID = studentId
+	ec.TargetID = roomId
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "student_id"}, {Name: "target_id"}},
+			DoUpdates: append(
+				clause.AssignmentColumns([]string{"limit", "room_name", "anomaly_alert", "anomaly_z_score", "updated_at"}),
+				clause.Assignment{Column: clause.Column{Name: "deleted_at"}, Value: nil},
+			),
+		}).Create(ec).Error
+		if err != nil {
+			return err
+		}
+
+		// 更新时拿不到可靠的自增 id,重新查一次
+		var id int64
+		err = tx.Model(&model.ElecpriceConfig{}).
+			Where("student_id = ? AND target_id = ?", studentId, roomId).
+			Pluck("id", &id).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Where("config_id = ?", id).Delete(&model.ElecpriceThreshold{}).Error
+		if err != nil {
+			return err
+		}
+		if len(thresholds) == 0 {
+			return nil
+		}
+		for i := range thresholds {
+			thresholds[i].ConfigID = id
+			thresholds[i].Sort = i
+		}
+		return tx.Create(&thresholds).Error
+	})
+}
+
+func (d *elecpriceDAO) FindThresholds(ctx context.Context, configIDs []int64) ([]model.ElecpriceThreshold, error) {
+	if len(configIDs) == 0 {
+		return nil, nil
 	}
-	return d.db.Create(ec).Error
+	var thresholds []model.ElecpriceThreshold
+	err := d.db.WithContext(ctx).
+		Where("config_id IN ?", configIDs).
+		Order("config_id ASC, sort ASC").
+		Find(&thresholds).Error
+	if err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+func (d *elecpriceDAO) UpdateThresholdAlertedAt(ctx context.Context, ids []int64, at int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).
+		Model(&model.ElecpriceThreshold{}).
+		Where("id IN ?", ids).
+		Update("last_alert_at", at).Error
+}
+
+func (d *elecpriceDAO) UpdateAnomalyAlertedAt(ctx context.Context, ids []int64, at int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).
+		Model(&model.ElecpriceConfig{}).
+		Where("id IN ?", ids).
+		Update("anomaly_alerted_at", at).Error
+}
+
+func (d *elecpriceDAO) SoftDeleteByIDs(ctx context.Context, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := d.db.WithContext(ctx).Where("id IN ?", ids).Delete(&model.ElecpriceConfig{})
+	return res.RowsAffected, res.Error
+}
+
+func (d *elecpriceDAO) CountDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Unscoped().
+		Model(&model.ElecpriceConfig{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Count(&count).Error
+	return count, err
+}
+
+func (d *elecpriceDAO) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先删除这些配置的阈值
+		deleted := tx.Unscoped().
+			Model(&model.ElecpriceConfig{}).
+			Select("id").
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before)
+		err := tx.Unscoped().Where("config_id IN (?)", deleted).Delete(&model.ElecpriceThreshold{}).Error
+		if err != nil {
+			return err
+		}
+
+		res := tx.Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Delete(&model.ElecpriceConfig{})
+		purged = res.RowsAffected
+		return res.Error
+	})
+	return purged, err
 }
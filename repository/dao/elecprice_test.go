@@ -0,0 +1,235 @@
+package dao
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/repository/dbtest"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func newTestDB(t *testing.T, db *gorm.DB) *gorm.DB {
+	t.Helper()
+	if err := InitTables(db); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	return db
+}
+
+func TestElecpriceDAO_Upsert(t *testing.T) {
+	type upsert struct {
+		limit      int64
+		thresholds []int64
+		// 在这次 Upsert 之后取消订阅
+		cancel bool
+	}
+	testCases := []struct {
+		name    string
+		upserts []upsert
+		// 期望的订阅状态,wantFound 为 false 时表示订阅已取消
+		wantFound      bool
+		wantLimit      int64
+		wantThresholds []int64
+		// 多次 Upsert 是否保持同一个 id
+		wantSameID bool
+	}{
+		{
+			name:           "新建订阅",
+			upserts:        []upsert{{limit: 10, thresholds: []int64{10, 5}}},
+			wantFound:      true,
+			wantLimit:      10,
+			wantThresholds: []int64{10, 5},
+			wantSameID:     true,
+		},
+		{
+			name: "更新订阅时替换阈值",
+			upserts: []upsert{
+				{limit: 10, thresholds: []int64{10, 5}},
+				{limit: 20, thresholds: []int64{3}},
+			},
+			wantFound:      true,
+			wantLimit:      20,
+			wantThresholds: []int64{3},
+			wantSameID:     true,
+		},
+		{
+			name: "清空阈值",
+			upserts: []upsert{
+				{limit: 10, thresholds: []int64{10, 5}},
+				{limit: 10},
+			},
+			wantFound:  true,
+			wantLimit:  10,
+			wantSameID: true,
+		},
+		{
+			name:      "取消订阅",
+			upserts:   []upsert{{limit: 10, thresholds: []int64{10}, cancel: true}},
+			wantFound: false,
+		},
+		{
+			name: "恢复已取消的订阅",
+			upserts: []upsert{
+				{limit: 10, thresholds: []int64{10}, cancel: true},
+				{limit: 30, thresholds: []int64{8, 4}},
+			},
+			wantFound:      true,
+			wantLimit:      30,
+			wantThresholds: []int64{8, 4},
+			wantSameID:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+				ctx := context.Background()
+				d := NewElecpriceDAO(newTestDB(t, db))
+
+				ids := map[int64]struct{}{}
+				for _, u := range tc.upserts {
+					var thresholds []model.ElecpriceThreshold
+					for _, limit := range u.thresholds {
+						thresholds = append(thresholds, model.ElecpriceThreshold{Limit: limit})
+					}
+					err := d.Upsert(ctx, "s1", "r1", &model.ElecpriceConfig{Limit: u.limit, RoomName: "东1-101"}, thresholds)
+					if err != nil {
+						t.Fatalf("Upsert 失败: %v", err)
+					}
+					var id int64
+					db.Unscoped().Model(&model.ElecpriceConfig{}).Where("student_id = ? AND target_id = ?", "s1", "r1").Pluck("id", &id)
+					ids[id] = struct{}{}
+					if u.cancel {
+						if err := d.Delete(ctx, "s1", "r1"); err != nil {
+							t.Fatalf("Delete 失败: %v", err)
+						}
+					}
+				}
+
+				var total int64
+				db.Unscoped().Model(&model.ElecpriceConfig{}).Count(&total)
+				if total != 1 {
+					t.Fatalf("配置行数 = %d, 期望 1", total)
+				}
+				if tc.wantSameID && len(ids) != 1 {
+					t.Fatalf("多次 Upsert 产生了不同的 id: %v", ids)
+				}
+
+				configs, err := d.FindAll(ctx, "s1")
+				if err != nil {
+					t.Fatalf("FindAll 失败: %v", err)
+				}
+				if !tc.wantFound {
+					if len(configs) != 0 {
+						t.Fatalf("已取消的订阅仍然可以查到: %+v", configs)
+					}
+					return
+				}
+				if len(configs) != 1 {
+					t.Fatalf("FindAll 返回 %d 条, 期望 1", len(configs))
+				}
+				if configs[0].Limit != tc.wantLimit {
+					t.Errorf("Limit = %d, 期望 %d", configs[0].Limit, tc.wantLimit)
+				}
+				if configs[0].DeletedAt.Valid {
+					t.Errorf("deleted_at 没有被清空")
+				}
+
+				thresholds, err := d.FindThresholds(ctx, []int64{configs[0].ID})
+				if err != nil {
+					t.Fatalf("FindThresholds 失败: %v", err)
+				}
+				var got []int64
+				for i, th := range thresholds {
+					if th.Sort != i {
+						t.Errorf("第 %d 个阈值的 Sort = %d", i, th.Sort)
+					}
+					got = append(got, th.Limit)
+				}
+				if !equalInt64s(got, tc.wantThresholds) {
+					t.Errorf("阈值 = %v, 期望 %v", got, tc.wantThresholds)
+				}
+			})
+		})
+	}
+}
+
+func TestElecpriceDAO_SoftDeleteAndPurge(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		ctx := context.Background()
+		d := NewElecpriceDAO(newTestDB(t, db))
+
+		for _, room := range []string{"r1", "r2"} {
+			thresholds := []model.ElecpriceThreshold{{Limit: 10}, {Limit: 5}}
+			if err := d.Upsert(ctx, "s1", room, &model.ElecpriceConfig{Limit: 10}, thresholds); err != nil {
+				t.Fatalf("Upsert 失败: %v", err)
+			}
+		}
+		configs, err := d.FindAll(ctx, "s1")
+		if err != nil || len(configs) != 2 {
+			t.Fatalf("FindAll = %d, %v", len(configs), err)
+		}
+		deletedID, keptID := configs[0].ID, configs[1].ID
+
+		deleted, err := d.SoftDeleteByIDs(ctx, []int64{deletedID})
+		if err != nil || deleted != 1 {
+			t.Fatalf("SoftDeleteByIDs = %d, %v", deleted, err)
+		}
+
+		now := time.Now()
+		testCases := []struct {
+			name   string
+			before time.Time
+			want   int64
+		}{
+			{name: "删除时间之前", before: now.Add(-time.Hour), want: 0},
+			{name: "删除时间之后", before: now.Add(time.Hour), want: 1},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				count, err := d.CountDeletedBefore(ctx, tc.before)
+				if err != nil {
+					t.Fatalf("CountDeletedBefore 失败: %v", err)
+				}
+				if count != tc.want {
+					t.Errorf("CountDeletedBefore = %d, 期望 %d", count, tc.want)
+				}
+			})
+		}
+
+		purged, err := d.PurgeDeletedBefore(ctx, now.Add(time.Hour))
+		if err != nil || purged != 1 {
+			t.Fatalf("PurgeDeletedBefore = %d, %v", purged, err)
+		}
+		var rows int64
+		db.Unscoped().Model(&model.ElecpriceConfig{}).Where("id = ?", deletedID).Count(&rows)
+		if rows != 0 {
+			t.Errorf("被清理的配置仍然存在")
+		}
+		thresholds, err := d.FindThresholds(ctx, []int64{deletedID, keptID})
+		if err != nil {
+			t.Fatalf("FindThresholds 失败: %v", err)
+		}
+		for _, th := range thresholds {
+			if th.ConfigID == deletedID {
+				t.Errorf("被清理配置的阈值没有删除: %+v", th)
+			}
+		}
+		if len(thresholds) != 2 {
+			t.Errorf("保留配置的阈值 = %d 个, 期望 2", len(thresholds))
+		}
+	})
+}
+
+func equalInt64s(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
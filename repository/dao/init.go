@@ -1,14 +1,33 @@
 package dao
 
 import (
+	"github.com/asynccnu/be-elecprice/repository/migration"
 	"github.com/asynccnu/be-elecprice/repository/model"
 	"gorm.io/gorm"
 )
 
+// InitTables 使用 AutoMigrate 建表,仅用于开发环境,其他环境使用 migration 包中的版本化迁移
 func InitTables(db *gorm.DB) error {
-	err := db.AutoMigrate(&model.ElecpriceConfig{})
+	// 唯一索引建立之前需要先合并已有的重复数据
+	err := mergeDuplicateConfigs(db)
+	if err != nil {
+		return err
+	}
+	err = db.AutoMigrate(&model.ElecpriceConfig{}, &model.JobRun{}, &model.ElecpriceReading{}, &model.ElecpriceThreshold{},
+		&model.NotificationPreference{}, &model.DeferredAlert{}, &model.AlertSnooze{}, &model.AlertHistory{},
+		&model.RoomGroup{}, &model.RoomGroupMember{}, &model.RoomRecharge{}, &model.MonthlyUsage{}, &model.LeaderboardRoom{})
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// mergeDuplicateConfigs 和版本化迁移使用相同的规则合并重复的配置
+func mergeDuplicateConfigs(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&model.ElecpriceConfig{}) {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return migration.MergeDuplicateConfigs(tx)
+	})
+}
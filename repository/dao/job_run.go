@@ -0,0 +1,45 @@
+package dao
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+)
+
+// JobRunDAO 任务执行记录的数据库操作
+type JobRunDAO interface {
+	Create(ctx context.Context, run *model.JobRun) error
+	Save(ctx context.Context, run *model.JobRun) error
+	List(ctx context.Context, name string, offset int, limit int) ([]model.JobRun, error)
+}
+
+type jobRunDAO struct {
+	db *gorm.DB
+}
+
+// NewJobRunDAO 构建任务执行记录的数据库操作实例
+func NewJobRunDAO(db *gorm.DB) JobRunDAO {
+	return &jobRunDAO{db: db}
+}
+
+func (d *jobRunDAO) Create(ctx context.Context, run *model.JobRun) error {
+	return d.db.WithContext(ctx).Create(run).Error
+}
+
+func (d *jobRunDAO) Save(ctx context.Context, run *model.JobRun) error {
+	return d.db.WithContext(ctx).Save(run).Error
+}
+
+func (d *jobRunDAO) List(ctx context.Context, name string, offset int, limit int) ([]model.JobRun, error) {
+	var runs []model.JobRun
+	query := d.db.WithContext(ctx).Order("id DESC").Offset(offset).Limit(limit)
+	// name 为空时返回所有任务的记录
+	if name != "" {
+		query = query.Where("name = ?", name)
+	}
+	err := query.Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LeaderboardDAO 节能排行榜报名的数据库操作
+type LeaderboardDAO interface {
+	// Upsert 同一个房间只报名一次,重复报名时更新楼栋和报名的学生
+	Upsert(ctx context.Context, r *model.LeaderboardRoom) error
+	Delete(ctx context.Context, roomId string) error
+	FindByArchitecture(ctx context.Context, architectureId string) ([]model.LeaderboardRoom, error)
+}
+
+type leaderboardDAO struct {
+	db *gorm.DB
+}
+
+// NewLeaderboardDAO 构建节能排行榜的数据库操作实例
+func NewLeaderboardDAO(db *gorm.DB) LeaderboardDAO {
+	return &leaderboardDAO{db: db}
+}
+
+func (d *leaderboardDAO) Upsert(ctx context.Context, r *model.LeaderboardRoom) error {
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"room_name", "architecture_id", "student_id", "updated_at"}),
+	}).Create(r).Error
+}
+
+func (d *leaderboardDAO) Delete(ctx context.Context, roomId string) error {
+	// 直接删除,重新报名时不会和软删除的记录冲突
+	return d.db.WithContext(ctx).Unscoped().Where("room_id = ?", roomId).Delete(&model.LeaderboardRoom{}).Error
+}
+
+func (d *leaderboardDAO) FindByArchitecture(ctx context.Context, architectureId string) ([]model.LeaderboardRoom, error) {
+	var rs []model.LeaderboardRoom
+	err := d.db.WithContext(ctx).Where("architecture_id = ?", architectureId).Order("id ASC").Find(&rs).Error
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MonthlyUsageDAO 房间月度用电的数据库操作
+type MonthlyUsageDAO interface {
+	// Upsert 同一个房间同一个月只保留一条,重复写入时覆盖
+	Upsert(ctx context.Context, u *model.MonthlyUsage) error
+	FindByRoomMonth(ctx context.Context, roomId string, month string) (model.MonthlyUsage, error)
+	// Average 统计某个月有用电数据的房间的平均用电,building 为空时统计全校
+	Average(ctx context.Context, month string, building string) (value float64, money float64, rooms int64, err error)
+	IsNotFoundError(err error) bool
+}
+
+type monthlyUsageDAO struct {
+	db *gorm.DB
+}
+
+// NewMonthlyUsageDAO 构建月度用电的数据库操作实例
+func NewMonthlyUsageDAO(db *gorm.DB) MonthlyUsageDAO {
+	return &monthlyUsageDAO{db: db}
+}
+
+func (d *monthlyUsageDAO) Upsert(ctx context.Context, u *model.MonthlyUsage) error {
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}, {Name: "month"}},
+		DoUpdates: clause.AssignmentColumns([]string{"room_name", "building", "usage_value", "usage_money", "days", "updated_at"}),
+	}).Create(u).Error
+}
+
+func (d *monthlyUsageDAO) FindByRoomMonth(ctx context.Context, roomId string, month string) (model.MonthlyUsage, error) {
+	var u model.MonthlyUsage
+	err := d.db.WithContext(ctx).Where("room_id = ? AND month = ?", roomId, month).First(&u).Error
+	return u, err
+}
+
+func (d *monthlyUsageDAO) Average(ctx context.Context, month string, building string) (float64, float64, int64, error) {
+	var res struct {
+		Value float64
+		Money float64
+		Rooms int64
+	}
+	db := d.db.WithContext(ctx).Model(&model.MonthlyUsage{}).
+		Select("COALESCE(AVG(usage_value), 0) AS value, COALESCE(AVG(usage_money), 0) AS money, COUNT(*) AS rooms").
+		// 没有名称的房间无法确定楼栋,不参与平均
+		Where("month = ? AND days > 0 AND room_name <> ''", month)
+	if building != "" {
+		db = db.Where("building = ?", building)
+	}
+	if err := db.Scan(&res).Error; err != nil {
+		return 0, 0, 0, err
+	}
+	return res.Value, res.Money, res.Rooms, nil
+}
+
+func (d *monthlyUsageDAO) IsNotFoundError(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,112 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DeferredAlertStatusPending = "pending"
+	DeferredAlertStatusSent    = "sent"
+	DeferredAlertStatusFailed  = "failed"
+)
+
+// NotificationDAO 提醒偏好和推迟发送的提醒的数据库操作
+type NotificationDAO interface {
+	FindPreference(ctx context.Context, studentId string) (model.NotificationPreference, error)
+	UpsertPreference(ctx context.Context, pref *model.NotificationPreference) error
+	// CreateDeferred 保存推迟发送的提醒,同一个学生同一个房间同一种类只保留最新的一条待发送提醒
+	// 被替换的提醒的历史记录标记为已替换
+	CreateDeferred(ctx context.Context, alert *model.DeferredAlert) error
+	// FindDueDeferred 获取计划发送时间不晚于 now 的待发送提醒
+	FindDueDeferred(ctx context.Context, now int64, limit int) ([]model.DeferredAlert, error)
+	UpdateDeferredStatus(ctx context.Context, id int64, status string, deliveredAt int64) error
+	IsNotFoundError(err error) bool
+}
+
+type notificationDAO struct {
+	db *gorm.DB
+}
+
+// NewNotificationDAO 构建提醒偏好的数据库操作实例
+func NewNotificationDAO(db *gorm.DB) NotificationDAO {
+	return &notificationDAO{db: db}
+}
+
+func (d *notificationDAO) FindPreference(ctx context.Context, studentId string) (model.NotificationPreference, error) {
+	var pref model.NotificationPreference
+	err := d.db.WithContext(ctx).Where("student_id = ?", studentId).First(&pref).Error
+	return pref, err
+}
+
+func (d *notificationDAO) UpsertPreference(ctx context.Context, pref *model.NotificationPreference) error {
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "student_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quiet_start", "quiet_end", "preferred_time", "timezone", "locale", "channel", "address", "updated_at"}),
+	}).Create(pref).Error
+}
+
+func (d *notificationDAO) CreateDeferred(ctx context.Context, alert *model.DeferredAlert) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var replaced []model.DeferredAlert
+		err := tx.Where("student_id = ? AND room_id = ? AND kind = ? AND status = ?",
+			alert.StudentID, alert.RoomID, alert.Kind, DeferredAlertStatusPending).
+			Find(&replaced).Error
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(replaced))
+		historyIDs := make([]int64, 0, len(replaced))
+		for _, r := range replaced {
+			ids = append(ids, r.ID)
+			if r.HistoryID != 0 {
+				historyIDs = append(historyIDs, r.HistoryID)
+			}
+		}
+		// 被替换的提醒不会再发送,对应的提醒历史标记为已替换
+		if len(historyIDs) > 0 {
+			err = tx.Model(&model.AlertHistory{}).
+				Where("id IN ?", historyIDs).
+				Update("status", AlertHistoryStatusSuperseded).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(ids) > 0 {
+			err = tx.Unscoped().Where("id IN ?", ids).Delete(&model.DeferredAlert{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		alert.Status = DeferredAlertStatusPending
+		return tx.Create(alert).Error
+	})
+}
+
+func (d *notificationDAO) FindDueDeferred(ctx context.Context, now int64, limit int) ([]model.DeferredAlert, error) {
+	var alerts []model.DeferredAlert
+	err := d.db.WithContext(ctx).
+		Where("status = ? AND deliver_at <= ?", DeferredAlertStatusPending, now).
+		Order("deliver_at ASC").
+		Limit(limit).
+		Find(&alerts).Error
+	if err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+func (d *notificationDAO) UpdateDeferredStatus(ctx context.Context, id int64, status string, deliveredAt int64) error {
+	return d.db.WithContext(ctx).
+		Model(&model.DeferredAlert{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"status": status, "delivered_at": deliveredAt}).Error
+}
+
+func (d *notificationDAO) IsNotFoundError(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
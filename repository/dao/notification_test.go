@@ -0,0 +1,107 @@
+package dao
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/repository/dbtest"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestNotificationDAO_CreateDeferred(t *testing.T) {
+	type deferred struct {
+		room, kind, title string
+	}
+	testCases := []struct {
+		name     string
+		deferred []deferred
+		// 最终待发送的提醒标题,按写入顺序
+		wantPending []string
+	}{
+		{
+			name:        "同一种类只保留最新的",
+			deferred:    []deferred{{"r1", "threshold", "a"}, {"r1", "threshold", "b"}},
+			wantPending: []string{"b"},
+		},
+		{
+			name:        "不同种类互不替换",
+			deferred:    []deferred{{"r1", "anomaly", "a"}, {"r1", "threshold", "b"}},
+			wantPending: []string{"a", "b"},
+		},
+		{
+			name:        "不同房间互不替换",
+			deferred:    []deferred{{"r1", "threshold", "a"}, {"r2", "threshold", "b"}},
+			wantPending: []string{"a", "b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+				ctx := context.Background()
+				d := NewNotificationDAO(newTestDB(t, db))
+				for i, a := range tc.deferred {
+					err := d.CreateDeferred(ctx, &model.DeferredAlert{StudentID: "s1", RoomID: a.room, Kind: a.kind, Title: a.title, DeliverAt: int64(100 + i)})
+					if err != nil {
+						t.Fatalf("CreateDeferred 失败: %v", err)
+					}
+				}
+
+				alerts, err := d.FindDueDeferred(ctx, 200, 10)
+				if err != nil {
+					t.Fatalf("FindDueDeferred 失败: %v", err)
+				}
+				var got []string
+				for _, a := range alerts {
+					got = append(got, a.Title)
+				}
+				if len(got) != len(tc.wantPending) {
+					t.Fatalf("待发送的提醒 = %v, 期望 %v", got, tc.wantPending)
+				}
+				for i := range got {
+					if got[i] != tc.wantPending[i] {
+						t.Fatalf("待发送的提醒 = %v, 期望 %v", got, tc.wantPending)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestNotificationDAO_CreateDeferredSupersedesHistory(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		ctx := context.Background()
+		d := NewNotificationDAO(newTestDB(t, db))
+		h := NewAlertHistoryDAO(db)
+
+		histories := make([]model.AlertHistory, 3)
+		for i := range histories {
+			histories[i] = model.AlertHistory{StudentID: "s1", RoomID: "r1", Status: "deferred"}
+			if err := h.Create(ctx, &histories[i]); err != nil {
+				t.Fatalf("保存提醒历史失败: %v", err)
+			}
+		}
+		alerts := []model.DeferredAlert{
+			{StudentID: "s1", RoomID: "r1", Kind: "threshold", HistoryID: histories[0].ID},
+			{StudentID: "s1", RoomID: "r1", Kind: "anomaly", HistoryID: histories[1].ID},
+			{StudentID: "s1", RoomID: "r1", Kind: "threshold", HistoryID: histories[2].ID},
+		}
+		for i := range alerts {
+			if err := d.CreateDeferred(ctx, &alerts[i]); err != nil {
+				t.Fatalf("CreateDeferred 失败: %v", err)
+			}
+		}
+
+		want := []string{AlertHistoryStatusSuperseded, "deferred", "deferred"}
+		got, err := h.ListByStudent(ctx, "s1", 0, 10)
+		if err != nil {
+			t.Fatalf("ListByStudent 失败: %v", err)
+		}
+		// ListByStudent 按 id 倒序
+		for i, hist := range got {
+			if w := want[len(want)-1-i]; hist.Status != w {
+				t.Errorf("提醒历史 %d 的状态 = %s, 期望 %s", hist.ID, hist.Status, w)
+			}
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+)
+
+// ReadingDAO 电费读数的数据库操作
+type ReadingDAO interface {
+	Create(ctx context.Context, r *model.ElecpriceReading) error
+	// FindLatest 获取房间最近的一次读数
+	FindLatest(ctx context.Context, roomId string) (model.ElecpriceReading, error)
+	// FindRange 获取房间在 [from, to) 之间的读数,按时间升序
+	FindRange(ctx context.Context, roomId string, from int64, to int64) ([]model.ElecpriceReading, error)
+	// FindRangeByRooms 批量获取多个房间在 [from, to) 之间的读数,按房间和时间升序
+	FindRangeByRooms(ctx context.Context, roomIds []string, from int64, to int64) ([]model.ElecpriceReading, error)
+	CountBefore(ctx context.Context, before int64) (int64, error)
+	// DeleteBefore 彻底删除最多 limit 条读取时间早于 before 的读数,返回删除的条数
+	DeleteBefore(ctx context.Context, before int64, limit int) (int64, error)
+}
+
+type readingDAO struct {
+	db *gorm.DB
+}
+
+// NewReadingDAO 构建电费读数的数据库操作实例
+func NewReadingDAO(db *gorm.DB) ReadingDAO {
+	return &readingDAO{db: db}
+}
+
+func (d *readingDAO) Create(ctx context.Context, r *model.ElecpriceReading) error {
+	return d.db.WithContext(ctx).Create(r).Error
+}
+
+func (d *readingDAO) FindLatest(ctx context.Context, roomId string) (model.ElecpriceReading, error) {
+	var r model.ElecpriceReading
+	err := d.db.WithContext(ctx).Where("room_id = ?", roomId).Order("read_at DESC").First(&r).Error
+	return r, err
+}
+
+func (d *readingDAO) FindRange(ctx context.Context, roomId string, from int64, to int64) ([]model.ElecpriceReading, error) {
+	var rs []model.ElecpriceReading
+	err := d.db.WithContext(ctx).
+		Where("room_id = ? AND read_at >= ? AND read_at < ?", roomId, from, to).
+		Order("read_at ASC").
+		Find(&rs).Error
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (d *readingDAO) FindRangeByRooms(ctx context.Context, roomIds []string, from int64, to int64) ([]model.ElecpriceReading, error) {
+	if len(roomIds) == 0 {
+		return nil, nil
+	}
+	var rs []model.ElecpriceReading
+	err := d.db.WithContext(ctx).
+		Where("room_id IN ? AND read_at >= ? AND read_at < ?", roomIds, from, to).
+		Order("room_id ASC, read_at ASC").
+		Find(&rs).Error
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (d *readingDAO) CountBefore(ctx context.Context, before int64) (int64, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&model.ElecpriceReading{}).Where("read_at < ?", before).Count(&count).Error
+	return count, err
+}
+
+func (d *readingDAO) DeleteBefore(ctx context.Context, before int64, limit int) (int64, error) {
+	// 先查出 id 再删除,mysql 不支持在 IN 子查询中使用 LIMIT,sqlite 不支持 DELETE ... LIMIT
+	var ids []int64
+	err := d.db.WithContext(ctx).Model(&model.ElecpriceReading{}).
+		Where("read_at < ?", before).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	res := d.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Delete(&model.ElecpriceReading{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,171 @@
+package dao
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/repository/dbtest"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// seedReadings 写入 r1 在 100/200/300 和 r2 在 150/250 的读数
+func seedReadings(t *testing.T, d ReadingDAO) {
+	t.Helper()
+	seeds := []struct {
+		room   string
+		readAt int64
+	}{
+		{"r1", 300}, {"r1", 100}, {"r2", 250}, {"r1", 200}, {"r2", 150},
+	}
+	for _, s := range seeds {
+		err := d.Create(context.Background(), &model.ElecpriceReading{RoomID: s.room, ReadAt: s.readAt, RemainMoney: float64(s.readAt)})
+		if err != nil {
+			t.Fatalf("写入读数失败: %v", err)
+		}
+	}
+}
+
+type roomReading struct {
+	room   string
+	readAt int64
+}
+
+func toRoomReadings(rs []model.ElecpriceReading) []roomReading {
+	var res []roomReading
+	for _, r := range rs {
+		res = append(res, roomReading{room: r.RoomID, readAt: r.ReadAt})
+	}
+	return res
+}
+
+func equalRoomReadings(a, b []roomReading) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadingDAO_FindRange(t *testing.T) {
+	testCases := []struct {
+		name     string
+		room     string
+		from, to int64
+		want     []roomReading
+	}{
+		{name: "全部读数按时间升序", room: "r1", from: 0, to: 1000, want: []roomReading{{"r1", 100}, {"r1", 200}, {"r1", 300}}},
+		{name: "包含起点不包含终点", room: "r1", from: 100, to: 300, want: []roomReading{{"r1", 100}, {"r1", 200}}},
+		{name: "区间内没有读数", room: "r1", from: 201, to: 300},
+		{name: "只返回指定房间", room: "r2", from: 0, to: 1000, want: []roomReading{{"r2", 150}, {"r2", 250}}},
+		{name: "房间不存在", room: "r3", from: 0, to: 1000},
+	}
+
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		d := NewReadingDAO(newTestDB(t, db))
+		seedReadings(t, d)
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				rs, err := d.FindRange(context.Background(), tc.room, tc.from, tc.to)
+				if err != nil {
+					t.Fatalf("FindRange 失败: %v", err)
+				}
+				if got := toRoomReadings(rs); !equalRoomReadings(got, tc.want) {
+					t.Errorf("FindRange = %v, 期望 %v", got, tc.want)
+				}
+			})
+		}
+	})
+}
+
+func TestReadingDAO_FindRangeByRooms(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rooms    []string
+		from, to int64
+		want     []roomReading
+	}{
+		{
+			name:  "按房间和时间升序",
+			rooms: []string{"r2", "r1"},
+			from:  0, to: 1000,
+			want: []roomReading{{"r1", 100}, {"r1", 200}, {"r1", 300}, {"r2", 150}, {"r2", 250}},
+		},
+		{
+			name:  "限定时间区间",
+			rooms: []string{"r1", "r2"},
+			from:  150, to: 250,
+			want: []roomReading{{"r1", 200}, {"r2", 150}},
+		},
+		{name: "没有房间", from: 0, to: 1000},
+	}
+
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		d := NewReadingDAO(newTestDB(t, db))
+		seedReadings(t, d)
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				rs, err := d.FindRangeByRooms(context.Background(), tc.rooms, tc.from, tc.to)
+				if err != nil {
+					t.Fatalf("FindRangeByRooms 失败: %v", err)
+				}
+				if got := toRoomReadings(rs); !equalRoomReadings(got, tc.want) {
+					t.Errorf("FindRangeByRooms = %v, 期望 %v", got, tc.want)
+				}
+			})
+		}
+	})
+}
+
+func TestReadingDAO_DeleteBefore(t *testing.T) {
+	testCases := []struct {
+		name        string
+		before      int64
+		limit       int
+		wantCount   int64
+		wantDeleted int64
+		wantLeft    int64
+	}{
+		{name: "没有过期读数", before: 100, limit: 10, wantCount: 0, wantDeleted: 0, wantLeft: 5},
+		{name: "删除全部过期读数", before: 251, limit: 10, wantCount: 4, wantDeleted: 4, wantLeft: 1},
+		{name: "按 limit 分批删除", before: 251, limit: 3, wantCount: 4, wantDeleted: 3, wantLeft: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+				ctx := context.Background()
+				d := NewReadingDAO(newTestDB(t, db))
+				seedReadings(t, d)
+
+				count, err := d.CountBefore(ctx, tc.before)
+				if err != nil {
+					t.Fatalf("CountBefore 失败: %v", err)
+				}
+				if count != tc.wantCount {
+					t.Errorf("CountBefore = %d, 期望 %d", count, tc.wantCount)
+				}
+
+				deleted, err := d.DeleteBefore(ctx, tc.before, tc.limit)
+				if err != nil {
+					t.Fatalf("DeleteBefore 失败: %v", err)
+				}
+				if deleted != tc.wantDeleted {
+					t.Errorf("DeleteBefore = %d, 期望 %d", deleted, tc.wantDeleted)
+				}
+
+				// 读数是彻底删除的,不能只是软删除
+				var left int64
+				db.Unscoped().Model(&model.ElecpriceReading{}).Count(&left)
+				if left != tc.wantLeft {
+					t.Errorf("剩余读数 = %d, 期望 %d", left, tc.wantLeft)
+				}
+			})
+		})
+	}
+}
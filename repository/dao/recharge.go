@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RechargeDAO 检测到的充值的数据库操作
+type RechargeDAO interface {
+	// Create 保存充值,同一次读数已经检测出充值时忽略,返回是否保存
+	Create(ctx context.Context, r *model.RoomRecharge) (bool, error)
+	FindByID(ctx context.Context, id int64) (model.RoomRecharge, error)
+	// FindRange 获取房间在 [from, to) 之间检测到的充值,按时间升序
+	FindRange(ctx context.Context, roomId string, from int64, to int64) ([]model.RoomRecharge, error)
+	// Claim 只认领还没有被认领的充值,返回是否认领成功
+	Claim(ctx context.Context, id int64, studentId string) (bool, error)
+	IsNotFoundError(err error) bool
+}
+
+type rechargeDAO struct {
+	db *gorm.DB
+}
+
+// NewRechargeDAO 构建充值记录的数据库操作实例
+func NewRechargeDAO(db *gorm.DB) RechargeDAO {
+	return &rechargeDAO{db: db}
+}
+
+func (d *rechargeDAO) Create(ctx context.Context, r *model.RoomRecharge) (bool, error) {
+	// 同一个房间的多次查询可能同时对比同一次读数,由 reading_id 的唯一索引去重
+	res := d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "reading_id"}},
+		DoNothing: true,
+	}).Create(r)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (d *rechargeDAO) FindByID(ctx context.Context, id int64) (model.RoomRecharge, error) {
+	var r model.RoomRecharge
+	err := d.db.WithContext(ctx).Where("id = ?", id).First(&r).Error
+	return r, err
+}
+
+func (d *rechargeDAO) FindRange(ctx context.Context, roomId string, from int64, to int64) ([]model.RoomRecharge, error) {
+	var rs []model.RoomRecharge
+	err := d.db.WithContext(ctx).
+		Where("room_id = ? AND detected_at >= ? AND detected_at < ?", roomId, from, to).
+		Order("detected_at ASC").
+		Find(&rs).Error
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (d *rechargeDAO) Claim(ctx context.Context, id int64, studentId string) (bool, error) {
+	res := d.db.WithContext(ctx).Model(&model.RoomRecharge{}).
+		Where("id = ? AND paid_by = ?", id, "").
+		Update("paid_by", studentId)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (d *rechargeDAO) IsNotFoundError(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,225 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoomGroupDAO 房间群组的数据库操作,群组相关的数据都是直接删除
+type RoomGroupDAO interface {
+	// Create 创建群组,同时把创建者加入群组并保存阈值
+	Create(ctx context.Context, g *model.RoomGroup, thresholds []model.ElecpriceThreshold) error
+	FindByID(ctx context.Context, id int64) (model.RoomGroup, error)
+	FindByInviteCode(ctx context.Context, code string) (model.RoomGroup, error)
+	FindByStudent(ctx context.Context, studentId string) ([]model.RoomGroup, error)
+	GetGroupsByCursor(ctx context.Context, lastID int64, limit int) ([]model.RoomGroup, int64, error)
+	// FindMembers 按加入顺序返回成员
+	FindMembers(ctx context.Context, groupIDs []int64) ([]model.RoomGroupMember, error)
+	FindThresholds(ctx context.Context, groupIDs []int64) ([]model.ElecpriceThreshold, error)
+	// AddMember 群组成员少于 limit 时加入成员,群组已满时返回 false,已经是成员时什么都不做
+	AddMember(ctx context.Context, groupId int64, studentId string, limit int) (bool, error)
+	RemoveMember(ctx context.Context, groupId int64, studentId string) error
+	// Update 更新群组的提醒方式并替换全部阈值
+	Update(ctx context.Context, groupId int64, deliveryMode string, thresholds []model.ElecpriceThreshold) error
+	UpdateOwner(ctx context.Context, groupId int64, ownerId string) error
+	// Delete 删除群组及其成员和阈值
+	Delete(ctx context.Context, groupId int64) error
+	// UpdateRotation 设置群组下一次轮流提醒的成员序号,cursors 的 key 为群组 ID
+	UpdateRotation(ctx context.Context, cursors map[int64]int64) error
+	IsNotFoundError(err error) bool
+}
+
+type roomGroupDAO struct {
+	db *gorm.DB
+}
+
+// NewRoomGroupDAO 构建房间群组的数据库操作实例
+func NewRoomGroupDAO(db *gorm.DB) RoomGroupDAO {
+	return &roomGroupDAO{db: db}
+}
+
+func (d *roomGroupDAO) Create(ctx context.Context, g *model.RoomGroup, thresholds []model.ElecpriceThreshold) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(g).Error; err != nil {
+			return err
+		}
+		err := tx.Create(&model.RoomGroupMember{GroupID: g.ID, StudentID: g.OwnerID}).Error
+		if err != nil {
+			return err
+		}
+		return createGroupThresholds(tx, g.ID, thresholds)
+	})
+}
+
+func (d *roomGroupDAO) FindByID(ctx context.Context, id int64) (model.RoomGroup, error) {
+	var g model.RoomGroup
+	err := d.db.WithContext(ctx).Where("id = ?", id).First(&g).Error
+	return g, err
+}
+
+func (d *roomGroupDAO) FindByInviteCode(ctx context.Context, code string) (model.RoomGroup, error) {
+	var g model.RoomGroup
+	err := d.db.WithContext(ctx).Where("invite_code = ?", code).First(&g).Error
+	return g, err
+}
+
+func (d *roomGroupDAO) FindByStudent(ctx context.Context, studentId string) ([]model.RoomGroup, error) {
+	var gs []model.RoomGroup
+	err := d.db.WithContext(ctx).
+		Where("id IN (?)", d.db.Model(&model.RoomGroupMember{}).Select("group_id").Where("student_id = ?", studentId)).
+		Order("id ASC").
+		Find(&gs).Error
+	if err != nil {
+		return nil, err
+	}
+	return gs, nil
+}
+
+func (d *roomGroupDAO) GetGroupsByCursor(ctx context.Context, lastID int64, limit int) ([]model.RoomGroup, int64, error) {
+	var gs []model.RoomGroup
+	err := d.db.WithContext(ctx).
+		Where("id > ?", lastID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&gs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(gs) == 0 {
+		return nil, lastID, nil
+	}
+	return gs, gs[len(gs)-1].ID, nil
+}
+
+func (d *roomGroupDAO) FindMembers(ctx context.Context, groupIDs []int64) ([]model.RoomGroupMember, error) {
+	if len(groupIDs) == 0 {
+		return nil, nil
+	}
+	var ms []model.RoomGroupMember
+	err := d.db.WithContext(ctx).
+		Where("group_id IN ?", groupIDs).
+		Order("group_id ASC, id ASC").
+		Find(&ms).Error
+	if err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *roomGroupDAO) FindThresholds(ctx context.Context, groupIDs []int64) ([]model.ElecpriceThreshold, error) {
+	if len(groupIDs) == 0 {
+		return nil, nil
+	}
+	var thresholds []model.ElecpriceThreshold
+	err := d.db.WithContext(ctx).
+		Where("group_id IN ?", groupIDs).
+		Order("group_id ASC, sort ASC").
+		Find(&thresholds).Error
+	if err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+func (d *roomGroupDAO) AddMember(ctx context.Context, groupId int64, studentId string, limit int) (bool, error) {
+	added := false
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁住群组,避免并发加入时超过人数上限
+		var g model.RoomGroup
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", groupId).First(&g).Error
+		if err != nil {
+			return err
+		}
+
+		var members []string
+		err = tx.Model(&model.RoomGroupMember{}).Where("group_id = ?", groupId).Pluck("student_id", &members).Error
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			if m == studentId {
+				added = true
+				return nil
+			}
+		}
+		if len(members) >= limit {
+			return nil
+		}
+
+		added = true
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.RoomGroupMember{GroupID: groupId, StudentID: studentId}).Error
+	})
+	return added, err
+}
+
+func (d *roomGroupDAO) RemoveMember(ctx context.Context, groupId int64, studentId string) error {
+	return d.db.WithContext(ctx).Unscoped().
+		Where("group_id = ? AND student_id = ?", groupId, studentId).
+		Delete(&model.RoomGroupMember{}).Error
+}
+
+func (d *roomGroupDAO) Update(ctx context.Context, groupId int64, deliveryMode string, thresholds []model.ElecpriceThreshold) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.RoomGroup{}).Where("id = ?", groupId).Update("delivery_mode", deliveryMode).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Where("group_id = ?", groupId).Delete(&model.ElecpriceThreshold{}).Error
+		if err != nil {
+			return err
+		}
+		return createGroupThresholds(tx, groupId, thresholds)
+	})
+}
+
+func (d *roomGroupDAO) UpdateOwner(ctx context.Context, groupId int64, ownerId string) error {
+	return d.db.WithContext(ctx).Model(&model.RoomGroup{}).Where("id = ?", groupId).Update("owner_id", ownerId).Error
+}
+
+func (d *roomGroupDAO) Delete(ctx context.Context, groupId int64) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("group_id = ?", groupId).Delete(&model.ElecpriceThreshold{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Where("group_id = ?", groupId).Delete(&model.RoomGroupMember{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id = ?", groupId).Delete(&model.RoomGroup{}).Error
+	})
+}
+
+func (d *roomGroupDAO) UpdateRotation(ctx context.Context, cursors map[int64]int64) error {
+	if len(cursors) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for id, cursor := range cursors {
+			err := tx.Model(&model.RoomGroup{}).Where("id = ?", id).Update("rotate_cursor", cursor).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (d *roomGroupDAO) IsNotFoundError(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+func createGroupThresholds(tx *gorm.DB, groupId int64, thresholds []model.ElecpriceThreshold) error {
+	if len(thresholds) == 0 {
+		return nil
+	}
+	for i := range thresholds {
+		thresholds[i].GroupID = groupId
+		thresholds[i].Sort = i
+	}
+	return tx.Create(&thresholds).Error
+}
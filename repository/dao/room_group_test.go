@@ -0,0 +1,87 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/repository/dbtest"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestRoomGroupDAO_AddMember(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 创建者之外已有的成员数
+		members   int
+		student   string
+		limit     int
+		wantAdded bool
+		wantCount int
+	}{
+		{name: "未满时加入", members: 1, student: "new", limit: 3, wantAdded: true, wantCount: 3},
+		{name: "已满时拒绝", members: 2, student: "new", limit: 3, wantAdded: false, wantCount: 3},
+		{name: "已满时已是成员", members: 2, student: "m0", limit: 3, wantAdded: true, wantCount: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+				ctx := context.Background()
+				d := NewRoomGroupDAO(newTestDB(t, db))
+				g := &model.RoomGroup{RoomID: "r1", OwnerID: "owner", InviteCode: "ABC"}
+				if err := d.Create(ctx, g, nil); err != nil {
+					t.Fatalf("创建群组失败: %v", err)
+				}
+				for i := 0; i < tc.members; i++ {
+					if _, err := d.AddMember(ctx, g.ID, fmt.Sprintf("m%d", i), tc.limit); err != nil {
+						t.Fatalf("AddMember 失败: %v", err)
+					}
+				}
+
+				added, err := d.AddMember(ctx, g.ID, tc.student, tc.limit)
+				if err != nil {
+					t.Fatalf("AddMember 失败: %v", err)
+				}
+				if added != tc.wantAdded {
+					t.Errorf("AddMember = %v, 期望 %v", added, tc.wantAdded)
+				}
+				members, err := d.FindMembers(ctx, []int64{g.ID})
+				if err != nil {
+					t.Fatalf("FindMembers 失败: %v", err)
+				}
+				if len(members) != tc.wantCount {
+					t.Errorf("成员数 = %d, 期望 %d", len(members), tc.wantCount)
+				}
+			})
+		})
+	}
+}
+
+func TestRoomGroupDAO_UpdateRotation(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		ctx := context.Background()
+		d := NewRoomGroupDAO(newTestDB(t, db))
+		var ids []int64
+		for _, code := range []string{"A", "B"} {
+			g := &model.RoomGroup{RoomID: "r1", OwnerID: "owner", InviteCode: code}
+			if err := d.Create(ctx, g, nil); err != nil {
+				t.Fatalf("创建群组失败: %v", err)
+			}
+			ids = append(ids, g.ID)
+		}
+
+		if err := d.UpdateRotation(ctx, map[int64]int64{ids[0]: 3}); err != nil {
+			t.Fatalf("UpdateRotation 失败: %v", err)
+		}
+		for i, want := range []int64{3, 0} {
+			g, err := d.FindByID(ctx, ids[i])
+			if err != nil {
+				t.Fatalf("FindByID 失败: %v", err)
+			}
+			if g.RotateCursor != want {
+				t.Errorf("群组 %d 的序号 = %d, 期望 %d", g.ID, g.RotateCursor, want)
+			}
+		}
+	})
+}
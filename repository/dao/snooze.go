@@ -0,0 +1,48 @@
+package dao
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SnoozeDAO 提醒暂停的数据库操作
+type SnoozeDAO interface {
+	// Upsert 同一个学生同一个房间只保留一条暂停记录,重复设置时覆盖
+	Upsert(ctx context.Context, s *model.AlertSnooze) error
+	FindByRoom(ctx context.Context, roomId string) ([]model.AlertSnooze, error)
+	DeleteByIDs(ctx context.Context, ids []int64) error
+}
+
+type snoozeDAO struct {
+	db *gorm.DB
+}
+
+// NewSnoozeDAO 构建提醒暂停的数据库操作实例
+func NewSnoozeDAO(db *gorm.DB) SnoozeDAO {
+	return &snoozeDAO{db: db}
+}
+
+func (d *snoozeDAO) Upsert(ctx context.Context, s *model.AlertSnooze) error {
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "student_id"}, {Name: "room_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"until", "remain", "updated_at"}),
+	}).Create(s).Error
+}
+
+func (d *snoozeDAO) FindByRoom(ctx context.Context, roomId string) ([]model.AlertSnooze, error) {
+	var ss []model.AlertSnooze
+	err := d.db.WithContext(ctx).Where("room_id = ?", roomId).Find(&ss).Error
+	if err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (d *snoozeDAO) DeleteByIDs(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Delete(&model.AlertSnooze{}).Error
+}
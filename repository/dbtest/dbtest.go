@@ -0,0 +1,75 @@
+// Package dbtest 为 repository 下的测试提供数据库
+package dbtest
+
+import (
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+	"os"
+	"testing"
+)
+
+// MySQLDSNEnv 设置后测试会同时在该 mysql 数据库上运行
+// 运行前会删除库中的所有表,只能指向专用的测试库,多个包共用时需要 go test -p 1
+const MySQLDSNEnv = "ELECPRICE_TEST_MYSQL_DSN"
+
+// Run 在每种测试数据库上各运行一次 fn,每次拿到的都是一个空库
+// 默认只使用内存 sqlite
+func Run(t *testing.T, fn func(t *testing.T, db *gorm.DB)) {
+	t.Run("sqlite", func(t *testing.T) {
+		fn(t, openSQLite(t))
+	})
+
+	dsn := os.Getenv(MySQLDSNEnv)
+	if dsn == "" {
+		return
+	}
+	t.Run("mysql", func(t *testing.T) {
+		fn(t, openMySQL(t, dsn))
+	})
+}
+
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: glogger.Discard})
+	if err != nil {
+		t.Fatalf("打开 sqlite 失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取 sqlite 连接失败: %v", err)
+	}
+	// 每个连接都是一个独立的内存数据库,限制为单连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	return db
+}
+
+func openMySQL(t *testing.T, dsn string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: glogger.Discard})
+	if err != nil {
+		t.Fatalf("打开 mysql 失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取 mysql 连接失败: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		t.Fatalf("获取 mysql 表失败: %v", err)
+	}
+	for _, table := range tables {
+		if err := db.Migrator().DropTable(table); err != nil {
+			t.Fatalf("清空 mysql 表 %s 失败: %v", table, err)
+		}
+	}
+	return db
+}
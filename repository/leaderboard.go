@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+)
+
+// LeaderboardRepository 报名参加节能排行榜的房间
+type LeaderboardRepository interface {
+	Save(ctx context.Context, r *domain.LeaderboardRoom) error
+	Delete(ctx context.Context, roomId string) error
+	FindByArchitecture(ctx context.Context, architectureId string) ([]*domain.LeaderboardRoom, error)
+}
+
+type leaderboardRepository struct {
+	dao dao.LeaderboardDAO
+}
+
+func NewLeaderboardRepository(dao dao.LeaderboardDAO) LeaderboardRepository {
+	return &leaderboardRepository{dao: dao}
+}
+
+func (r *leaderboardRepository) Save(ctx context.Context, room *domain.LeaderboardRoom) error {
+	return r.dao.Upsert(ctx, &model.LeaderboardRoom{
+		RoomID:         room.RoomId,
+		RoomName:       room.RoomName,
+		ArchitectureID: room.ArchitectureId,
+		StudentID:      room.StudentId,
+	})
+}
+
+func (r *leaderboardRepository) Delete(ctx context.Context, roomId string) error {
+	return r.dao.Delete(ctx, roomId)
+}
+
+func (r *leaderboardRepository) FindByArchitecture(ctx context.Context, architectureId string) ([]*domain.LeaderboardRoom, error) {
+	rs, err := r.dao.FindByArchitecture(ctx, architectureId)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*domain.LeaderboardRoom, 0, len(rs))
+	for _, m := range rs {
+		res = append(res, &domain.LeaderboardRoom{
+			RoomId:         m.RoomID,
+			RoomName:       m.RoomName,
+			ArchitectureId: m.ArchitectureID,
+			StudentId:      m.StudentID,
+		})
+	}
+	return res, nil
+}
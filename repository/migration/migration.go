@@ -0,0 +1,159 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+// Migration 一次版本化的表结构变更,Up 和 Down 互为逆操作
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt int64
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 单个迁移的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt int64
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	l          logger.Logger
+}
+
+// NewMigrator 使用 migrations 中注册的全部迁移构建迁移器
+func NewMigrator(db *gorm.DB, l logger.Logger) *Migrator {
+	ms := make([]Migration, len(migrations))
+	copy(ms, migrations)
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].Version < ms[j].Version
+	})
+	return &Migrator{db: db, migrations: ms, l: l}
+}
+
+// Up 依次执行未执行的迁移直到 target 版本,target 为 0 时执行到最新版本,返回执行的数量
+// 每个迁移在一个事务中执行,但 MySQL 的 DDL 会隐式提交,事务不能保证迁移的原子性,
+// 迁移需要能够在部分变更已经生效时重新执行
+func (m *Migrator) Up(ctx context.Context, target int64) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := mig.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				AppliedAt: time.Now().Unix(),
+			}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("执行迁移 %d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		m.l.Info("执行迁移成功", logger.Int64("version", mig.Version), logger.String("name", mig.Name))
+		count++
+	}
+	return count, nil
+}
+
+// Down 按版本从新到旧回滚 steps 个已执行的迁移,返回回滚的数量
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == nil {
+			return count, fmt.Errorf("迁移 %d_%s 不支持回滚", mig.Version, mig.Name)
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := mig.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, mig.Version).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("回滚迁移 %d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		m.l.Info("回滚迁移成功", logger.Int64("version", mig.Version), logger.String("name", mig.Name))
+		count++
+	}
+	return count, nil
+}
+
+// Status 返回所有已注册迁移的执行状态
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if record, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = record.AppliedAt
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// applied 读取已执行的迁移,schema_migrations 表不存在时先创建
+func (m *Migrator) applied(ctx context.Context) (map[int64]SchemaMigration, error) {
+	db := m.db.WithContext(ctx)
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	res := make(map[int64]SchemaMigration, len(records))
+	for _, r := range records {
+		res[r.Version] = r
+	}
+	return res, nil
+}
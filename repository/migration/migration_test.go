@@ -0,0 +1,213 @@
+package migration
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository/dbtest"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func newTestMigrator(db *gorm.DB) *Migrator {
+	return NewMigrator(db, logger.NewZapLogger(zap.NewNop()))
+}
+
+func appliedVersions(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status 失败: %v", err)
+	}
+	var res []int64
+	for _, s := range statuses {
+		if s.Applied {
+			res = append(res, s.Version)
+		}
+	}
+	return res
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		ctx := context.Background()
+		m := newTestMigrator(db)
+		latest := m.migrations[len(m.migrations)-1].Version
+
+		n, err := m.Up(ctx, 3)
+		if err != nil || n != 3 {
+			t.Fatalf("Up(3) = %d, %v", n, err)
+		}
+		if got := appliedVersions(t, m); len(got) != 3 || got[2] != 3 {
+			t.Fatalf("Up(3) 之后已执行 %v", got)
+		}
+
+		n, err = m.Up(ctx, 0)
+		if err != nil || n != len(m.migrations)-3 {
+			t.Fatalf("Up(0) = %d, %v", n, err)
+		}
+		if got := appliedVersions(t, m); int64(len(got)) != latest {
+			t.Fatalf("Up(0) 之后已执行 %v, 期望到 %d", got, latest)
+		}
+
+		// 已经是最新版本时不再执行
+		n, err = m.Up(ctx, 0)
+		if err != nil || n != 0 {
+			t.Fatalf("重复 Up(0) = %d, %v", n, err)
+		}
+
+		n, err = m.Down(ctx, 1)
+		if err != nil || n != 1 {
+			t.Fatalf("Down(1) = %d, %v", n, err)
+		}
+		if got := appliedVersions(t, m); got[len(got)-1] != latest-1 {
+			t.Fatalf("Down(1) 之后最新版本为 %d, 期望 %d", got[len(got)-1], latest-1)
+		}
+
+		n, err = m.Down(ctx, len(m.migrations))
+		if err != nil || n != len(m.migrations)-1 {
+			t.Fatalf("Down(all) = %d, %v", n, err)
+		}
+		if got := appliedVersions(t, m); len(got) != 0 {
+			t.Fatalf("全部回滚之后仍有已执行的迁移 %v", got)
+		}
+		for _, table := range []string{"elecprice_configs", "elecprice_readings", "job_runs"} {
+			if db.Migrator().HasTable(table) {
+				t.Errorf("全部回滚之后表 %s 仍然存在", table)
+			}
+		}
+
+		// 回滚之后可以重新执行到最新版本
+		n, err = m.Up(ctx, 0)
+		if err != nil || n != len(m.migrations) {
+			t.Fatalf("回滚后 Up(0) = %d, %v", n, err)
+		}
+	})
+}
+
+func TestMergeDuplicateConfigs(t *testing.T) {
+	type row struct {
+		student, target string
+		deleted         bool
+	}
+	testCases := []struct {
+		name string
+		rows []row
+		// 合并后保留的行在 rows 中的下标
+		wantKept []int
+	}{
+		{
+			name:     "没有重复",
+			rows:     []row{{"s1", "r1", false}, {"s1", "r2", false}, {"s2", "r1", true}},
+			wantKept: []int{0, 1, 2},
+		},
+		{
+			name:     "都未删除时保留最后写入的",
+			rows:     []row{{"s1", "r1", false}, {"s1", "r1", false}, {"s1", "r1", false}},
+			wantKept: []int{2},
+		},
+		{
+			name:     "优先保留未删除的",
+			rows:     []row{{"s1", "r1", false}, {"s1", "r1", true}},
+			wantKept: []int{0},
+		},
+		{
+			name:     "全部已删除时保留最后写入的",
+			rows:     []row{{"s1", "r1", true}, {"s1", "r1", true}},
+			wantKept: []int{1},
+		},
+		{
+			name: "多组重复互不影响",
+			rows: []row{
+				{"s1", "r1", false}, {"s1", "r1", false}, {"s1", "r1", true},
+				{"s2", "r1", true}, {"s2", "r1", false},
+			},
+			wantKept: []int{1, 4},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+				ctx := context.Background()
+				m := newTestMigrator(db)
+				// 唯一索引在版本 3 建立,先停在版本 2 写入重复数据
+				if _, err := m.Up(ctx, 2); err != nil {
+					t.Fatalf("Up(2) 失败: %v", err)
+				}
+
+				now := time.Now()
+				ids := make([]int64, len(tc.rows))
+				for i, r := range tc.rows {
+					c := elecpriceConfigV1{StudentID: r.student, TargetID: r.target}
+					c.Base.CreatedAt, c.Base.UpdatedAt = now.Unix(), now.Unix()
+					if r.deleted {
+						c.Base.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+					}
+					if err := db.Create(&c).Error; err != nil {
+						t.Fatalf("写入配置失败: %v", err)
+					}
+					ids[i] = c.Base.ID
+				}
+
+				if _, err := m.Up(ctx, 3); err != nil {
+					t.Fatalf("Up(3) 失败: %v", err)
+				}
+
+				var got []int64
+				if err := db.Table("elecprice_configs").Order("id ASC").Pluck("id", &got).Error; err != nil {
+					t.Fatalf("查询配置失败: %v", err)
+				}
+				var want []int64
+				for _, i := range tc.wantKept {
+					want = append(want, ids[i])
+				}
+				if len(got) != len(want) {
+					t.Fatalf("保留 %v, 期望 %v", got, want)
+				}
+				for i := range got {
+					if got[i] != want[i] {
+						t.Fatalf("保留 %v, 期望 %v", got, want)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestMigrator_RetryPartialMigration(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		ctx := context.Background()
+		m := newTestMigrator(db)
+		if _, err := m.Up(ctx, 15); err != nil {
+			t.Fatalf("Up(15) 失败: %v", err)
+		}
+
+		// 模拟版本 16 在 MySQL 中加列之后失败,列已经存在但没有迁移记录
+		if err := db.Migrator().AddColumn(&roomRechargeV16{}, "ReadingID"); err != nil {
+			t.Fatalf("加列失败: %v", err)
+		}
+		n, err := m.Up(ctx, 0)
+		if err != nil {
+			t.Fatalf("重新执行迁移失败: %v", err)
+		}
+		if n != len(m.migrations)-15 {
+			t.Errorf("Up(0) = %d, 期望 %d", n, len(m.migrations)-15)
+		}
+		if !db.Migrator().HasIndex(&roomRechargeV16{}, "ReadingID") {
+			t.Errorf("重新执行后没有创建索引")
+		}
+
+		// 回滚同样可以在部分变更已经撤销时重新执行
+		if err := db.Migrator().DropColumn(&jobRunV17{}, "Pruned"); err != nil {
+			t.Fatalf("删列失败: %v", err)
+		}
+		if _, err := m.Down(ctx, len(m.migrations)-16); err != nil {
+			t.Fatalf("重新执行回滚失败: %v", err)
+		}
+		if db.Migrator().HasColumn(&jobRunV17{}, "Sampled") {
+			t.Errorf("回滚后 Sampled 列仍然存在")
+		}
+	})
+}
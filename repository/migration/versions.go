@@ -0,0 +1,625 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// migrations 所有已注册的迁移,只能追加新的版本,不能修改已发布的版本
+// 迁移中使用的结构体是当时表结构的快照,不要直接引用 model 包
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_elecprice_configs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&elecpriceConfigV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&elecpriceConfigV1{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_job_runs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&jobRunV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&jobRunV2{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "unique_elecprice_configs_student_target",
+		Up: func(tx *gorm.DB) error {
+			// 唯一索引建立之前需要先合并已有的重复数据
+			if err := MergeDuplicateConfigs(tx); err != nil {
+				return err
+			}
+			return tx.AutoMigrate(&elecpriceConfigV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			// sqlite 删除列时会重建表,回滚之后的版本后索引可能已经不存在
+			return dropIndex(tx, &elecpriceConfigV3{}, "idx_student_target")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create_elecprice_readings",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&elecpriceReadingV4{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&elecpriceReadingV4{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_elecprice_thresholds",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&elecpriceThresholdV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&elecpriceThresholdV5{})
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create_notification_preferences_and_deferred_alerts",
+		Up: func(tx *gorm.DB) error {
+			err := tx.AutoMigrate(&notificationPreferenceV6{}, &deferredAlertV6{})
+			if err != nil {
+				return err
+			}
+			return addColumn(tx, &jobRunV6{}, "Deferred")
+		},
+		Down: func(tx *gorm.DB) error {
+			err := dropColumn(tx, &jobRunV6{}, "Deferred")
+			if err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&notificationPreferenceV6{}, &deferredAlertV6{})
+		},
+	},
+	{
+		Version: 7,
+		Name:    "create_alert_snoozes",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&alertSnoozeV7{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&alertSnoozeV7{})
+		},
+	},
+	{
+		Version: 8,
+		Name:    "create_alert_histories",
+		Up: func(tx *gorm.DB) error {
+			err := tx.AutoMigrate(&alertHistoryV8{})
+			if err != nil {
+				return err
+			}
+			return addColumn(tx, &deferredAlertV8{}, "HistoryID")
+		},
+		Down: func(tx *gorm.DB) error {
+			err := dropColumn(tx, &deferredAlertV8{}, "HistoryID")
+			if err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&alertHistoryV8{})
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add_notification_preferences_locale",
+		Up: func(tx *gorm.DB) error {
+			return addColumn(tx, &notificationPreferenceV9{}, "Locale")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, &notificationPreferenceV9{}, "Locale")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add_notification_channels",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, &notificationPreferenceV10{}, "Channel"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, &notificationPreferenceV10{}, "Address"); err != nil {
+				return err
+			}
+			return addColumn(tx, &alertHistoryV10{}, "Channel")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumn(tx, &alertHistoryV10{}, "Channel"); err != nil {
+				return err
+			}
+			if err := dropColumn(tx, &notificationPreferenceV10{}, "Address"); err != nil {
+				return err
+			}
+			return dropColumn(tx, &notificationPreferenceV10{}, "Channel")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "create_room_groups",
+		Up: func(tx *gorm.DB) error {
+			err := tx.AutoMigrate(&roomGroupV11{}, &roomGroupMemberV11{})
+			if err != nil {
+				return err
+			}
+			if err := addColumn(tx, &elecpriceThresholdV11{}, "GroupID"); err != nil {
+				return err
+			}
+			return createIndex(tx, &elecpriceThresholdV11{}, "GroupID")
+		},
+		Down: func(tx *gorm.DB) error {
+			// 群组的阈值没有所属的订阅,回滚时一起删除
+			if tx.Migrator().HasColumn(&elecpriceThresholdV11{}, "GroupID") {
+				err := tx.Exec("DELETE FROM elecprice_thresholds WHERE group_id <> 0").Error
+				if err != nil {
+					return err
+				}
+			}
+			if err := dropIndex(tx, &elecpriceThresholdV11{}, "GroupID"); err != nil {
+				return err
+			}
+			if err := dropColumn(tx, &elecpriceThresholdV11{}, "GroupID"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&roomGroupV11{}, &roomGroupMemberV11{})
+		},
+	},
+	{
+		Version: 12,
+		Name:    "create_room_recharges",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&roomRechargeV12{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&roomRechargeV12{})
+		},
+	},
+	{
+		Version: 13,
+		Name:    "create_monthly_usages",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&monthlyUsageV13{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&monthlyUsageV13{})
+		},
+	},
+	{
+		Version: 14,
+		Name:    "add_anomaly_alert_to_elecprice_configs",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"AnomalyAlert", "AnomalyZScore", "AnomalyAlertedAt"} {
+				if err := addColumn(tx, &elecpriceConfigV14{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range []string{"AnomalyAlert", "AnomalyZScore", "AnomalyAlertedAt"} {
+				if err := dropColumn(tx, &elecpriceConfigV14{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 15,
+		Name:    "create_leaderboard_rooms",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&leaderboardRoomV15{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&leaderboardRoomV15{})
+		},
+	},
+	{
+		Version: 16,
+		Name:    "add_reading_id_to_room_recharges",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, &roomRechargeV16{}, "ReadingID"); err != nil {
+				return err
+			}
+			return createIndex(tx, &roomRechargeV16{}, "ReadingID")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndex(tx, &roomRechargeV16{}, "ReadingID"); err != nil {
+				return err
+			}
+			return dropColumn(tx, &roomRechargeV16{}, "ReadingID")
+		},
+	},
+	{
+		Version: 17,
+		Name:    "add_sampled_and_pruned_to_job_runs",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"Sampled", "Pruned"} {
+				if err := addColumn(tx, &jobRunV17{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range []string{"Sampled", "Pruned"} {
+				if err := dropColumn(tx, &jobRunV17{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 18,
+		Name:    "add_kind_to_deferred_alerts",
+		Up: func(tx *gorm.DB) error {
+			return addColumn(tx, &deferredAlertV18{}, "Kind")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, &deferredAlertV18{}, "Kind")
+		},
+	},
+}
+
+// addColumn 列不存在时才添加
+// MySQL 的 DDL 会隐式提交,迁移中途失败时前面的变更已经生效,迁移中的表结构变更都先检查当前的状态,
+// 这样重新执行失败的迁移可以跳过已经完成的部分
+func addColumn(tx *gorm.DB, value any, field string) error {
+	if tx.Migrator().HasColumn(value, field) {
+		return nil
+	}
+	return tx.Migrator().AddColumn(value, field)
+}
+
+// dropColumn 列存在时才删除
+func dropColumn(tx *gorm.DB, value any, field string) error {
+	if !tx.Migrator().HasColumn(value, field) {
+		return nil
+	}
+	return tx.Migrator().DropColumn(value, field)
+}
+
+// createIndex 索引不存在时才创建
+func createIndex(tx *gorm.DB, value any, name string) error {
+	if tx.Migrator().HasIndex(value, name) {
+		return nil
+	}
+	return tx.Migrator().CreateIndex(value, name)
+}
+
+// dropIndex 索引存在时才删除
+func dropIndex(tx *gorm.DB, value any, name string) error {
+	if !tx.Migrator().HasIndex(value, name) {
+		return nil
+	}
+	return tx.Migrator().DropIndex(value, name)
+}
+
+// MergeDuplicateConfigs 对同一个 (student_id, target_id) 只保留一条配置
+// 优先保留未删除的配置中 id 最大即最后写入的一条,全部已删除时保留 id 最大的一条
+func MergeDuplicateConfigs(tx *gorm.DB) error {
+	var dups []struct {
+		StudentID string
+		TargetID  string
+		KeepID    int64
+	}
+	err := tx.Table("elecprice_configs").
+		Select("student_id, target_id, COALESCE(MAX(CASE WHEN deleted_at IS NULL THEN id END), MAX(id)) AS keep_id").
+		Group("student_id, target_id").
+		Having("COUNT(*) > 1").
+		Scan(&dups).Error
+	if err != nil {
+		return err
+	}
+	for _, dup := range dups {
+		err := tx.Exec("DELETE FROM elecprice_configs WHERE student_id = ? AND target_id = ? AND id <> ?",
+			dup.StudentID, dup.TargetID, dup.KeepID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type baseModelV1 struct {
+	ID        int64          `gorm:"primaryKey;autoIncrement;column:id"`
+	CreatedAt int64          `gorm:"column:created_at;not null"`
+	UpdatedAt int64          `gorm:"column:updated_at;not null"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+type elecpriceConfigV1 struct {
+	StudentID string
+	Limit     int64
+	TargetID  string
+	RoomName  string
+	Base      baseModelV1 `gorm:"embedded"`
+}
+
+func (elecpriceConfigV1) TableName() string {
+	return "elecprice_configs"
+}
+
+type jobRunV2 struct {
+	Name      string `gorm:"column:name;index"`
+	DryRun    bool
+	Status    string
+	StartedAt int64
+	EndedAt   int64
+	Checked   int64
+	Sent      int64
+	Failed    int64
+	Deleted   int64
+	Purged    int64
+	Error     string      `gorm:"type:text"`
+	Base      baseModelV1 `gorm:"embedded"`
+}
+
+func (jobRunV2) TableName() string {
+	return "job_runs"
+}
+
+type elecpriceConfigV3 struct {
+	StudentID string `gorm:"size:64;uniqueIndex:idx_student_target"`
+	Limit     int64
+	TargetID  string `gorm:"size:64;uniqueIndex:idx_student_target"`
+	RoomName  string
+	Base      baseModelV1 `gorm:"embedded"`
+}
+
+func (elecpriceConfigV3) TableName() string {
+	return "elecprice_configs"
+}
+
+type elecpriceReadingV4 struct {
+	RoomID            string `gorm:"size:64;index:idx_room_read_at"`
+	RemainMoney       float64
+	YesterdayUseValue float64
+	YesterdayUseMoney float64
+	ReadAt            int64       `gorm:"index:idx_room_read_at"`
+	Base              baseModelV1 `gorm:"embedded"`
+}
+
+func (elecpriceReadingV4) TableName() string {
+	return "elecprice_readings"
+}
+
+type elecpriceThresholdV5 struct {
+	ConfigID    int64 `gorm:"index"`
+	Sort        int
+	Limit       int64
+	Severity    string `gorm:"size:16"`
+	Template    string `gorm:"type:text"`
+	Cooldown    int64
+	LastAlertAt int64
+	Base        baseModelV1 `gorm:"embedded"`
+}
+
+func (elecpriceThresholdV5) TableName() string {
+	return "elecprice_thresholds"
+}
+
+type notificationPreferenceV6 struct {
+	StudentID     string      `gorm:"size:64;uniqueIndex"`
+	QuietStart    string      `gorm:"size:5"`
+	QuietEnd      string      `gorm:"size:5"`
+	PreferredTime string      `gorm:"size:5"`
+	Timezone      string      `gorm:"size:64"`
+	Base          baseModelV1 `gorm:"embedded"`
+}
+
+func (notificationPreferenceV6) TableName() string {
+	return "notification_preferences"
+}
+
+type deferredAlertV6 struct {
+	StudentID   string `gorm:"size:64;index:idx_student_room"`
+	RoomID      string `gorm:"size:64;index:idx_student_room"`
+	Type        string `gorm:"size:32"`
+	Title       string `gorm:"size:255"`
+	Content     string `gorm:"type:text"`
+	DeliverAt   int64  `gorm:"index"`
+	Status      string `gorm:"size:16;index"`
+	DeliveredAt int64
+	Base        baseModelV1 `gorm:"embedded"`
+}
+
+func (deferredAlertV6) TableName() string {
+	return "deferred_alerts"
+}
+
+// jobRunV6 只包含新增的列
+type jobRunV6 struct {
+	Deferred int64
+}
+
+func (jobRunV6) TableName() string {
+	return "job_runs"
+}
+
+type alertSnoozeV7 struct {
+	StudentID string `gorm:"size:64;uniqueIndex:idx_snooze_student_room"`
+	RoomID    string `gorm:"size:64;uniqueIndex:idx_snooze_student_room;index"`
+	Until     int64
+	Remain    float64
+	Base      baseModelV1 `gorm:"embedded"`
+}
+
+func (alertSnoozeV7) TableName() string {
+	return "alert_snoozes"
+}
+
+type alertHistoryV8 struct {
+	StudentID    string `gorm:"size:64;index"`
+	RoomID       string `gorm:"size:64"`
+	RoomName     string
+	Remain       string `gorm:"size:32"`
+	Limit        int64
+	Severity     string `gorm:"size:16"`
+	Title        string `gorm:"size:255"`
+	Content      string `gorm:"type:text"`
+	Status       string `gorm:"size:16"`
+	FeedResponse string `gorm:"type:text"`
+	DeliveredAt  int64
+	Base         baseModelV1 `gorm:"embedded"`
+}
+
+func (alertHistoryV8) TableName() string {
+	return "alert_histories"
+}
+
+// deferredAlertV8 只包含新增的列
+type deferredAlertV8 struct {
+	HistoryID int64
+}
+
+func (deferredAlertV8) TableName() string {
+	return "deferred_alerts"
+}
+
+// notificationPreferenceV9 只包含新增的列
+type notificationPreferenceV9 struct {
+	Locale string `gorm:"size:16"`
+}
+
+func (notificationPreferenceV9) TableName() string {
+	return "notification_preferences"
+}
+
+// notificationPreferenceV10 只包含新增的列
+type notificationPreferenceV10 struct {
+	Channel string `gorm:"size:16"`
+	Address string `gorm:"size:255"`
+}
+
+func (notificationPreferenceV10) TableName() string {
+	return "notification_preferences"
+}
+
+// alertHistoryV10 只包含新增的列
+type alertHistoryV10 struct {
+	Channel string `gorm:"size:16"`
+}
+
+func (alertHistoryV10) TableName() string {
+	return "alert_histories"
+}
+
+type roomGroupV11 struct {
+	RoomID       string `gorm:"size:64;index"`
+	RoomName     string
+	OwnerID      string `gorm:"size:64"`
+	InviteCode   string `gorm:"size:16;uniqueIndex"`
+	DeliveryMode string `gorm:"size:16"`
+	RotateCursor int64
+	Base         baseModelV1 `gorm:"embedded"`
+}
+
+func (roomGroupV11) TableName() string {
+	return "room_groups"
+}
+
+type roomGroupMemberV11 struct {
+	GroupID   int64       `gorm:"uniqueIndex:idx_group_student"`
+	StudentID string      `gorm:"size:64;uniqueIndex:idx_group_student;index"`
+	Base      baseModelV1 `gorm:"embedded"`
+}
+
+func (roomGroupMemberV11) TableName() string {
+	return "room_group_members"
+}
+
+// elecpriceThresholdV11 只包含新增的列
+type elecpriceThresholdV11 struct {
+	GroupID int64 `gorm:"index"`
+}
+
+func (elecpriceThresholdV11) TableName() string {
+	return "elecprice_thresholds"
+}
+
+type roomRechargeV12 struct {
+	RoomID     string `gorm:"size:64;index:idx_room_detected"`
+	Amount     float64
+	Before     float64
+	After      float64
+	DetectedAt int64       `gorm:"index:idx_room_detected"`
+	PaidBy     string      `gorm:"size:64"`
+	Base       baseModelV1 `gorm:"embedded"`
+}
+
+func (roomRechargeV12) TableName() string {
+	return "room_recharges"
+}
+
+type monthlyUsageV13 struct {
+	RoomID     string `gorm:"size:64;uniqueIndex:idx_room_month"`
+	Month      string `gorm:"size:7;uniqueIndex:idx_room_month;index:idx_month_building"`
+	RoomName   string
+	Building   string `gorm:"size:64;index:idx_month_building"`
+	UsageValue float64
+	UsageMoney float64
+	Days       int64
+	Base       baseModelV1 `gorm:"embedded"`
+}
+
+func (monthlyUsageV13) TableName() string {
+	return "monthly_usages"
+}
+
+// elecpriceConfigV14 只包含新增的列
+type elecpriceConfigV14 struct {
+	AnomalyAlert     bool
+	AnomalyZScore    float64
+	AnomalyAlertedAt int64
+}
+
+func (elecpriceConfigV14) TableName() string {
+	return "elecprice_configs"
+}
+
+type leaderboardRoomV15 struct {
+	RoomID         string `gorm:"size:64;uniqueIndex"`
+	RoomName       string
+	ArchitectureID string      `gorm:"size:64;index"`
+	StudentID      string      `gorm:"size:64"`
+	Base           baseModelV1 `gorm:"embedded"`
+}
+
+func (leaderboardRoomV15) TableName() string {
+	return "leaderboard_rooms"
+}
+
+type roomRechargeV16 struct {
+	ReadingID *int64 `gorm:"uniqueIndex"`
+}
+
+func (roomRechargeV16) TableName() string {
+	return "room_recharges"
+}
+
+// jobRunV17 只包含新增的列
+type jobRunV17 struct {
+	Sampled int64
+	Pruned  int64
+}
+
+func (jobRunV17) TableName() string {
+	return "job_runs"
+}
+
+// deferredAlertV18 只包含新增的列
+type deferredAlertV18 struct {
+	Kind string `gorm:"size:64"`
+}
+
+func (deferredAlertV18) TableName() string {
+	return "deferred_alerts"
+}
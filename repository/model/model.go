@@ -6,10 +6,162 @@ import (
 )
 
 type ElecpriceConfig struct {
-	StudentID string // 学生号
+	StudentID string `gorm:"size:64;uniqueIndex:idx_student_target"` // 学生号
 	Limit     int64  //金额
-	TargetID  string // 房间ID
+	TargetID  string `gorm:"size:64;uniqueIndex:idx_student_target"` // 房间ID
 	RoomName  string // 房间名称
+	// 用电异常提醒
+	AnomalyAlert     bool    // 是否开启
+	AnomalyZScore    float64 // 判定异常的标准分数,0 表示使用默认值
+	AnomalyAlertedAt int64   // 最近一次发出用电异常提醒的时间
+	BaseModel
+}
+
+// ElecpriceThreshold 订阅或房间群组的提醒阈值,一个订阅可以有多个
+type ElecpriceThreshold struct {
+	ConfigID    int64  `gorm:"index"` // 所属订阅,属于群组时为 0
+	GroupID     int64  `gorm:"index"` // 所属房间群组,属于订阅时为 0
+	Sort        int    // 在订阅中的顺序
+	Limit       int64  // 金额
+	Severity    string `gorm:"size:16"`   // 严重程度 info/warning/critical
+	Template    string `gorm:"type:text"` // 提醒内容模板,为空时使用默认内容
+	Cooldown    int64  // 两次提醒的最小间隔,单位秒
+	LastAlertAt int64  // 上次提醒时间
+	BaseModel
+}
+
+// RoomGroup 室友共享的房间订阅,阈值属于整个群组
+type RoomGroup struct {
+	RoomID       string `gorm:"size:64;index"` // 房间ID
+	RoomName     string // 房间名称
+	OwnerID      string `gorm:"size:64"`             // 创建者学号,只有创建者可以修改群组
+	InviteCode   string `gorm:"size:16;uniqueIndex"` // 邀请码
+	DeliveryMode string `gorm:"size:16"`             // all 提醒所有成员, rotate 轮流提醒一位成员
+	RotateCursor int64  // 轮流提醒时下一次提醒的成员序号
+	BaseModel
+}
+
+// RoomGroupMember 房间群组的成员
+type RoomGroupMember struct {
+	GroupID   int64  `gorm:"uniqueIndex:idx_group_student"`               // 所属群组
+	StudentID string `gorm:"size:64;uniqueIndex:idx_group_student;index"` // 学生号
+	BaseModel
+}
+
+// RoomRecharge 根据相邻两次读数余额上升检测到的充值
+type RoomRecharge struct {
+	RoomID     string  `gorm:"size:64;index:idx_room_detected"` // 房间ID
+	Amount     float64 // 充值金额,按余额上升计算,不含两次读数之间的用电
+	Before     float64 // 充值前的余额
+	After      float64 // 充值后的余额
+	DetectedAt int64   `gorm:"index:idx_room_detected"` // 检测到充值的时间
+	PaidBy     string  `gorm:"size:64"`                 // 认领的学生,未认领时为空
+	// ReadingID 检测充值时对比的上一次读数,同一次读数只会检测出一次充值,旧数据为空
+	ReadingID *int64 `gorm:"uniqueIndex"`
+	BaseModel
+}
+
+// MonthlyUsage 房间一个月的用电量,由学校接口的每日用电汇总得到
+type MonthlyUsage struct {
+	RoomID     string  `gorm:"size:64;uniqueIndex:idx_room_month"`                         // 房间ID
+	Month      string  `gorm:"size:7;uniqueIndex:idx_room_month;index:idx_month_building"` // 月份,格式为 YYYY-MM
+	RoomName   string  // 房间名称
+	Building   string  `gorm:"size:64;index:idx_month_building"` // 所在楼栋,由房间名称推断
+	UsageValue float64 // 用电量,单位度
+	UsageMoney float64 // 电费,单位元
+	Days       int64   // 有用电数据的天数
+	BaseModel
+}
+
+// LeaderboardRoom 自愿参加同楼栋节能排行榜的房间
+type LeaderboardRoom struct {
+	RoomID         string `gorm:"size:64;uniqueIndex"` // 房间ID
+	RoomName       string // 房间名称
+	ArchitectureID string `gorm:"size:64;index"` // 所在楼栋
+	StudentID      string `gorm:"size:64"`       // 报名的学生
+	BaseModel
+}
+
+// NotificationPreference 学生的提醒偏好
+type NotificationPreference struct {
+	StudentID     string `gorm:"size:64;uniqueIndex"` // 学生号
+	QuietStart    string `gorm:"size:5"`              // 免打扰开始时间 HH:MM
+	QuietEnd      string `gorm:"size:5"`              // 免打扰结束时间 HH:MM
+	PreferredTime string `gorm:"size:5"`              // 每日推送时间 HH:MM
+	Timezone      string `gorm:"size:64"`             // 时区
+	Locale        string `gorm:"size:16"`             // 提醒使用的语言,如 zh-CN
+	Channel       string `gorm:"size:16"`             // 提醒渠道 feed/webhook/email,为空时使用默认渠道
+	Address       string `gorm:"size:255"`            // 渠道地址,如 webhook 地址或邮箱
+	BaseModel
+}
+
+// AlertSnooze 学生对某个房间的提醒暂停,确认提醒时 Until 为 0
+type AlertSnooze struct {
+	StudentID string  `gorm:"size:64;uniqueIndex:idx_snooze_student_room"`       // 学生号
+	RoomID    string  `gorm:"size:64;uniqueIndex:idx_snooze_student_room;index"` // 房间ID
+	Until     int64   // 暂停截止时间,0 表示不按时间恢复
+	Remain    float64 // 暂停时的电费余额
+	BaseModel
+}
+
+// DeferredAlert 被推迟发送的提醒
+type DeferredAlert struct {
+	StudentID   string `gorm:"size:64;index:idx_student_room"` // 学生号
+	RoomID      string `gorm:"size:64;index:idx_student_room"` // 房间ID
+	Type        string `gorm:"size:32"`                        // feed 事件类型
+	Kind        string `gorm:"size:64"`                        // 提醒的种类,只有同一种类的待发送提醒会相互替换
+	Title       string `gorm:"size:255"`                       // 标题
+	Content     string `gorm:"type:text"`                      // 内容
+	DeliverAt   int64  `gorm:"index"`                          // 计划发送时间
+	Status      string `gorm:"size:16;index"`                  // pending/sent/failed
+	DeliveredAt int64  // 实际发送时间
+	HistoryID   int64  // 对应的提醒历史记录
+	BaseModel
+}
+
+// AlertHistory 发给学生的提醒记录
+type AlertHistory struct {
+	StudentID    string `gorm:"size:64;index"` // 学生号
+	RoomID       string `gorm:"size:64"`       // 房间ID
+	RoomName     string // 房间名称
+	Remain       string `gorm:"size:32"` // 提醒时的电费余额
+	Limit        int64  // 触发的阈值
+	Severity     string `gorm:"size:16"`   // 严重程度
+	Title        string `gorm:"size:255"`  // 标题
+	Content      string `gorm:"type:text"` // 内容
+	Status       string `gorm:"size:16"`   // sent/failed/deferred/superseded
+	Channel      string `gorm:"size:16"`   // 实际使用的提醒渠道
+	FeedResponse string `gorm:"type:text"` // feed 服务的返回或错误信息
+	DeliveredAt  int64  // 实际发送时间,推迟的提醒发送前为 0
+	BaseModel
+}
+
+// JobRun 定时任务的执行记录
+type JobRun struct {
+	Name      string `gorm:"column:name;index"` // 任务名称
+	DryRun    bool   // 是否为试运行
+	Status    string // 执行状态 running/success/failed
+	StartedAt int64  // 开始时间
+	EndedAt   int64  // 结束时间
+	Checked   int64  // 检查的房间数
+	Sent      int64  // 发出的提醒数
+	Failed    int64  // 失败数
+	Deleted   int64  // 软删除的订阅数
+	Purged    int64  // 彻底删除的订阅数
+	Deferred  int64  // 因免打扰或推送时间推迟的提醒数
+	Sampled   int64  // 后台采样成功的房间数
+	Pruned    int64  // 清理的过期读数数
+	Error     string `gorm:"type:text"` // 错误信息
+	BaseModel
+}
+
+// ElecpriceReading 房间的电费读数
+type ElecpriceReading struct {
+	RoomID            string  `gorm:"size:64;index:idx_room_read_at"` // 房间ID
+	RemainMoney       float64 // 剩余金额
+	YesterdayUseValue float64 // 昨日用电量
+	YesterdayUseMoney float64 // 昨日用电金额
+	ReadAt            int64   `gorm:"index:idx_room_read_at"` // 读取时间
 	BaseModel
 }
 
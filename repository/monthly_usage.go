@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+)
+
+// MonthlyUsageRepository 房间月度用电的存储
+type MonthlyUsageRepository interface {
+	Save(ctx context.Context, u *domain.MonthlyUsage) error
+	Find(ctx context.Context, roomId string, month string) (*domain.MonthlyUsage, error)
+	// Average 统计某个月的平均用电,building 为空时统计全校
+	Average(ctx context.Context, month string, building string) (domain.UsageAverage, error)
+	IsNotFoundError(err error) bool
+}
+
+type monthlyUsageRepository struct {
+	dao dao.MonthlyUsageDAO
+}
+
+func NewMonthlyUsageRepository(dao dao.MonthlyUsageDAO) MonthlyUsageRepository {
+	return &monthlyUsageRepository{dao: dao}
+}
+
+func (r *monthlyUsageRepository) Save(ctx context.Context, u *domain.MonthlyUsage) error {
+	return r.dao.Upsert(ctx, &model.MonthlyUsage{
+		RoomID:     u.RoomId,
+		Month:      u.Month,
+		RoomName:   u.RoomName,
+		Building:   u.Building,
+		UsageValue: u.Value,
+		UsageMoney: u.Money,
+		Days:       u.Days,
+	})
+}
+
+func (r *monthlyUsageRepository) Find(ctx context.Context, roomId string, month string) (*domain.MonthlyUsage, error) {
+	m, err := r.dao.FindByRoomMonth(ctx, roomId, month)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.MonthlyUsage{
+		RoomId:   m.RoomID,
+		RoomName: m.RoomName,
+		Building: m.Building,
+		Month:    m.Month,
+		Value:    m.UsageValue,
+		Money:    m.UsageMoney,
+		Days:     m.Days,
+	}, nil
+}
+
+func (r *monthlyUsageRepository) Average(ctx context.Context, month string, building string) (domain.UsageAverage, error) {
+	value, money, rooms, err := r.dao.Average(ctx, month, building)
+	if err != nil {
+		return domain.UsageAverage{}, err
+	}
+	return domain.UsageAverage{Value: value, Money: money, Rooms: rooms}, nil
+}
+
+func (r *monthlyUsageRepository) IsNotFoundError(err error) bool {
+	return r.dao.IsNotFoundError(err)
+}
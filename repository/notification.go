@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+)
+
+// NotificationRepository 学生的提醒偏好和推迟发送的提醒
+type NotificationRepository interface {
+	// FindPreference 获取学生的提醒偏好,没有设置过时返回空的偏好
+	FindPreference(ctx context.Context, studentId string) (*domain.NotificationPreference, error)
+	SavePreference(ctx context.Context, pref *domain.NotificationPreference) error
+	SaveDeferred(ctx context.Context, alert *domain.DeferredAlert) error
+	FindDueDeferred(ctx context.Context, now int64, limit int) ([]*domain.DeferredAlert, error)
+	// MarkDeferred 记录推迟的提醒的发送结果
+	MarkDeferred(ctx context.Context, id int64, delivered bool, at int64) error
+}
+
+type notificationRepository struct {
+	dao dao.NotificationDAO
+}
+
+func NewNotificationRepository(dao dao.NotificationDAO) NotificationRepository {
+	return &notificationRepository{dao: dao}
+}
+
+func (r *notificationRepository) FindPreference(ctx context.Context, studentId string) (*domain.NotificationPreference, error) {
+	pref, err := r.dao.FindPreference(ctx, studentId)
+	if r.dao.IsNotFoundError(err) {
+		return &domain.NotificationPreference{StudentId: studentId}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &domain.NotificationPreference{
+		StudentId:     pref.StudentID,
+		QuietStart:    pref.QuietStart,
+		QuietEnd:      pref.QuietEnd,
+		PreferredTime: pref.PreferredTime,
+		Timezone:      pref.Timezone,
+		Locale:        pref.Locale,
+		Channel:       pref.Channel,
+		Address:       pref.Address,
+	}, nil
+}
+
+func (r *notificationRepository) SavePreference(ctx context.Context, pref *domain.NotificationPreference) error {
+	return r.dao.UpsertPreference(ctx, &model.NotificationPreference{
+		StudentID:     pref.StudentId,
+		QuietStart:    pref.QuietStart,
+		QuietEnd:      pref.QuietEnd,
+		PreferredTime: pref.PreferredTime,
+		Timezone:      pref.Timezone,
+		Locale:        pref.Locale,
+		Channel:       pref.Channel,
+		Address:       pref.Address,
+	})
+}
+
+func (r *notificationRepository) SaveDeferred(ctx context.Context, alert *domain.DeferredAlert) error {
+	return r.dao.CreateDeferred(ctx, &model.DeferredAlert{
+		StudentID: alert.Event.StudentId,
+		RoomID:    alert.Event.RoomId,
+		Type:      alert.Event.Type,
+		Kind:      alert.Kind,
+		Title:     alert.Event.Title,
+		Content:   alert.Event.Content,
+		DeliverAt: alert.DeliverAt,
+		HistoryID: alert.HistoryID,
+	})
+}
+
+func (r *notificationRepository) FindDueDeferred(ctx context.Context, now int64, limit int) ([]*domain.DeferredAlert, error) {
+	alerts, err := r.dao.FindDueDeferred(ctx, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*domain.DeferredAlert, 0, len(alerts))
+	for _, a := range alerts {
+		res = append(res, &domain.DeferredAlert{
+			ID: a.ID,
+			Event: &domain.FeedEvent{
+				StudentId: a.StudentID,
+				RoomId:    a.RoomID,
+				Type:      a.Type,
+				Title:     a.Title,
+				Content:   a.Content,
+			},
+			Kind:      a.Kind,
+			DeliverAt: a.DeliverAt,
+			HistoryID: a.HistoryID,
+		})
+	}
+	return res, nil
+}
+
+func (r *notificationRepository) MarkDeferred(ctx context.Context, id int64, delivered bool, at int64) error {
+	status := dao.DeferredAlertStatusSent
+	if !delivered {
+		status = dao.DeferredAlertStatusFailed
+	}
+	return r.dao.UpdateDeferredStatus(ctx, id, status, at)
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/cache"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+)
+
+// ReadingRepository 电费读数的存储
+type ReadingRepository interface {
+	// Save 保存读数并通知正在订阅该房间的客户端
+	Save(ctx context.Context, r *domain.Reading) error
+	FindLatest(ctx context.Context, roomId string) (*domain.Reading, error)
+	// FindRange 获取房间在 [from, to) 之间的读数,按时间升序
+	FindRange(ctx context.Context, roomId string, from int64, to int64) ([]*domain.Reading, error)
+	// FindRangeByRooms 批量获取多个房间在 [from, to) 之间的读数,按房间分组,每个房间按时间升序
+	FindRangeByRooms(ctx context.Context, roomIds []string, from int64, to int64) (map[string][]*domain.Reading, error)
+	CountBefore(ctx context.Context, before int64) (int64, error)
+	// DeleteBefore 彻底删除最多 limit 条读取时间早于 before 的读数,返回删除的条数
+	DeleteBefore(ctx context.Context, before int64, limit int) (int64, error)
+	// Subscribe 订阅房间之后保存的读数,调用返回的函数取消订阅
+	Subscribe(roomIds []string) (<-chan *domain.Reading, func())
+}
+
+type readingRepository struct {
+	dao    dao.ReadingDAO
+	broker cache.ReadingBroker
+}
+
+func NewReadingRepository(dao dao.ReadingDAO, broker cache.ReadingBroker) ReadingRepository {
+	return &readingRepository{dao: dao, broker: broker}
+}
+
+func (r *readingRepository) Save(ctx context.Context, reading *domain.Reading) error {
+	err := r.dao.Create(ctx, &model.ElecpriceReading{
+		RoomID:            reading.RoomId,
+		RemainMoney:       reading.RemainMoney,
+		YesterdayUseValue: reading.YesterdayUseValue,
+		YesterdayUseMoney: reading.YesterdayUseMoney,
+		ReadAt:            reading.ReadAt,
+	})
+	if err != nil {
+		return err
+	}
+	// 读数已经保存,广播失败时本副本的订阅者仍会收到,其他副本的客户端等下一次读数
+	_ = r.broker.Publish(ctx, reading)
+	return nil
+}
+
+func (r *readingRepository) CountBefore(ctx context.Context, before int64) (int64, error) {
+	return r.dao.CountBefore(ctx, before)
+}
+
+func (r *readingRepository) DeleteBefore(ctx context.Context, before int64, limit int) (int64, error) {
+	return r.dao.DeleteBefore(ctx, before, limit)
+}
+
+func (r *readingRepository) Subscribe(roomIds []string) (<-chan *domain.Reading, func()) {
+	return r.broker.Subscribe(roomIds)
+}
+
+func (r *readingRepository) FindLatest(ctx context.Context, roomId string) (*domain.Reading, error) {
+	reading, err := r.dao.FindLatest(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomain(&reading), nil
+}
+
+func (r *readingRepository) FindRange(ctx context.Context, roomId string, from int64, to int64) ([]*domain.Reading, error) {
+	readings, err := r.dao.FindRange(ctx, roomId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*domain.Reading, 0, len(readings))
+	for i := range readings {
+		res = append(res, r.toDomain(&readings[i]))
+	}
+	return res, nil
+}
+
+func (r *readingRepository) FindRangeByRooms(ctx context.Context, roomIds []string, from int64, to int64) (map[string][]*domain.Reading, error) {
+	res := make(map[string][]*domain.Reading, len(roomIds))
+	// 分批查询,避免 IN 的参数过多
+	const batch = 500
+	for start := 0; start < len(roomIds); start += batch {
+		end := min(start+batch, len(roomIds))
+		readings, err := r.dao.FindRangeByRooms(ctx, roomIds[start:end], from, to)
+		if err != nil {
+			return nil, err
+		}
+		for i := range readings {
+			res[readings[i].RoomID] = append(res[readings[i].RoomID], r.toDomain(&readings[i]))
+		}
+	}
+	return res, nil
+}
+
+func (r *readingRepository) toDomain(m *model.ElecpriceReading) *domain.Reading {
+	return &domain.Reading{
+		ID:                m.ID,
+		RoomId:            m.RoomID,
+		RemainMoney:       m.RemainMoney,
+		YesterdayUseValue: m.YesterdayUseValue,
+		YesterdayUseMoney: m.YesterdayUseMoney,
+		ReadAt:            m.ReadAt,
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+)
+
+// RechargeRepository 根据读数检测到的房间充值
+type RechargeRepository interface {
+	// Save 保存充值,同一次读数已经检测出充值时忽略,此时 r.ID 为 0
+	Save(ctx context.Context, r *domain.Recharge) error
+	FindByID(ctx context.Context, id int64) (*domain.Recharge, error)
+	// FindRange 获取房间在 [from, to) 之间检测到的充值,按时间升序
+	FindRange(ctx context.Context, roomId string, from int64, to int64) ([]*domain.Recharge, error)
+	// Claim 记录充值由哪位学生支付,充值已经被认领时返回 false
+	Claim(ctx context.Context, id int64, studentId string) (bool, error)
+	IsNotFoundError(err error) bool
+}
+
+type rechargeRepository struct {
+	dao dao.RechargeDAO
+}
+
+func NewRechargeRepository(dao dao.RechargeDAO) RechargeRepository {
+	return &rechargeRepository{dao: dao}
+}
+
+func (r *rechargeRepository) Save(ctx context.Context, recharge *domain.Recharge) error {
+	m := &model.RoomRecharge{
+		RoomID:     recharge.RoomId,
+		Amount:     recharge.Amount,
+		Before:     recharge.Before,
+		After:      recharge.After,
+		DetectedAt: recharge.DetectedAt,
+		PaidBy:     recharge.PaidBy,
+	}
+	if recharge.ReadingId != 0 {
+		m.ReadingID = &recharge.ReadingId
+	}
+	created, err := r.dao.Create(ctx, m)
+	if err != nil {
+		return err
+	}
+	if created {
+		recharge.ID = m.ID
+	}
+	return nil
+}
+
+func (r *rechargeRepository) FindByID(ctx context.Context, id int64) (*domain.Recharge, error) {
+	m, err := r.dao.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomain(&m), nil
+}
+
+func (r *rechargeRepository) FindRange(ctx context.Context, roomId string, from int64, to int64) ([]*domain.Recharge, error) {
+	rs, err := r.dao.FindRange(ctx, roomId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*domain.Recharge, 0, len(rs))
+	for i := range rs {
+		res = append(res, r.toDomain(&rs[i]))
+	}
+	return res, nil
+}
+
+func (r *rechargeRepository) Claim(ctx context.Context, id int64, studentId string) (bool, error) {
+	return r.dao.Claim(ctx, id, studentId)
+}
+
+func (r *rechargeRepository) IsNotFoundError(err error) bool {
+	return r.dao.IsNotFoundError(err)
+}
+
+func (r *rechargeRepository) toDomain(m *model.RoomRecharge) *domain.Recharge {
+	res := &domain.Recharge{
+		ID:         m.ID,
+		RoomId:     m.RoomID,
+		Amount:     m.Amount,
+		Before:     m.Before,
+		After:      m.After,
+		DetectedAt: m.DetectedAt,
+		PaidBy:     m.PaidBy,
+	}
+	if m.ReadingID != nil {
+		res.ReadingId = *m.ReadingID
+	}
+	return res
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+)
+
+// RoomGroupRepository 室友共享的房间群组
+type RoomGroupRepository interface {
+	Create(ctx context.Context, g *domain.RoomGroup) error
+	FindByID(ctx context.Context, id int64) (*domain.RoomGroup, error)
+	FindByInviteCode(ctx context.Context, code string) (*domain.RoomGroup, error)
+	FindByStudent(ctx context.Context, studentId string) ([]*domain.RoomGroup, error)
+	FindByCursor(ctx context.Context, lastID int64, limit int) ([]*domain.RoomGroup, int64, error)
+	// AddMember 群组成员少于 limit 时加入成员,群组已满时返回 false
+	AddMember(ctx context.Context, groupId int64, studentId string, limit int) (bool, error)
+	RemoveMember(ctx context.Context, groupId int64, studentId string) error
+	Update(ctx context.Context, g *domain.RoomGroup) error
+	UpdateOwner(ctx context.Context, groupId int64, ownerId string) error
+	Delete(ctx context.Context, groupId int64) error
+	// UpdateRotation 轮流提醒后,设置群组下一次提醒的成员序号
+	UpdateRotation(ctx context.Context, cursors map[int64]int64) error
+	IsNotFoundError(err error) bool
+}
+
+type roomGroupRepository struct {
+	dao dao.RoomGroupDAO
+}
+
+func NewRoomGroupRepository(dao dao.RoomGroupDAO) RoomGroupRepository {
+	return &roomGroupRepository{dao: dao}
+}
+
+func (r *roomGroupRepository) Create(ctx context.Context, g *domain.RoomGroup) error {
+	m := &model.RoomGroup{
+		RoomID:       g.RoomId,
+		RoomName:     g.RoomName,
+		OwnerID:      g.OwnerId,
+		InviteCode:   g.InviteCode,
+		DeliveryMode: g.DeliveryMode,
+	}
+	if err := r.dao.Create(ctx, m, toThresholdEntities(g.Thresholds)); err != nil {
+		return err
+	}
+	g.ID = m.ID
+	g.Members = []string{g.OwnerId}
+	return nil
+}
+
+func (r *roomGroupRepository) FindByID(ctx context.Context, id int64) (*domain.RoomGroup, error) {
+	g, err := r.dao.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	gs, err := r.toDomains(ctx, []model.RoomGroup{g})
+	if err != nil {
+		return nil, err
+	}
+	return gs[0], nil
+}
+
+func (r *roomGroupRepository) FindByInviteCode(ctx context.Context, code string) (*domain.RoomGroup, error) {
+	g, err := r.dao.FindByInviteCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	gs, err := r.toDomains(ctx, []model.RoomGroup{g})
+	if err != nil {
+		return nil, err
+	}
+	return gs[0], nil
+}
+
+func (r *roomGroupRepository) FindByStudent(ctx context.Context, studentId string) ([]*domain.RoomGroup, error) {
+	gs, err := r.dao.FindByStudent(ctx, studentId)
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomains(ctx, gs)
+}
+
+func (r *roomGroupRepository) FindByCursor(ctx context.Context, lastID int64, limit int) ([]*domain.RoomGroup, int64, error) {
+	gs, nextID, err := r.dao.GetGroupsByCursor(ctx, lastID, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := r.toDomains(ctx, gs)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, nextID, nil
+}
+
+func (r *roomGroupRepository) AddMember(ctx context.Context, groupId int64, studentId string, limit int) (bool, error) {
+	return r.dao.AddMember(ctx, groupId, studentId, limit)
+}
+
+func (r *roomGroupRepository) RemoveMember(ctx context.Context, groupId int64, studentId string) error {
+	return r.dao.RemoveMember(ctx, groupId, studentId)
+}
+
+func (r *roomGroupRepository) Update(ctx context.Context, g *domain.RoomGroup) error {
+	return r.dao.Update(ctx, g.ID, g.DeliveryMode, toThresholdEntities(g.Thresholds))
+}
+
+func (r *roomGroupRepository) UpdateOwner(ctx context.Context, groupId int64, ownerId string) error {
+	return r.dao.UpdateOwner(ctx, groupId, ownerId)
+}
+
+func (r *roomGroupRepository) Delete(ctx context.Context, groupId int64) error {
+	return r.dao.Delete(ctx, groupId)
+}
+
+func (r *roomGroupRepository) UpdateRotation(ctx context.Context, cursors map[int64]int64) error {
+	return r.dao.UpdateRotation(ctx, cursors)
+}
+
+func (r *roomGroupRepository) IsNotFoundError(err error) bool {
+	return r.dao.IsNotFoundError(err)
+}
+
+// toDomains 批量加载群组的成员和阈值
+func (r *roomGroupRepository) toDomains(ctx context.Context, gs []model.RoomGroup) ([]*domain.RoomGroup, error) {
+	ids := make([]int64, 0, len(gs))
+	for _, g := range gs {
+		ids = append(ids, g.ID)
+	}
+	members, err := r.dao.FindMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	thresholds, err := r.dao.FindThresholds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byGroupMembers := make(map[int64][]string, len(gs))
+	for _, m := range members {
+		byGroupMembers[m.GroupID] = append(byGroupMembers[m.GroupID], m.StudentID)
+	}
+	byGroupThresholds := make(map[int64][]*domain.Threshold, len(gs))
+	for _, t := range thresholds {
+		byGroupThresholds[t.GroupID] = append(byGroupThresholds[t.GroupID], &domain.Threshold{
+			ID:          t.ID,
+			Limit:       t.Limit,
+			Severity:    t.Severity,
+			Template:    t.Template,
+			Cooldown:    t.Cooldown,
+			LastAlertAt: t.LastAlertAt,
+		})
+	}
+
+	res := make([]*domain.RoomGroup, 0, len(gs))
+	for _, g := range gs {
+		res = append(res, &domain.RoomGroup{
+			ID:           g.ID,
+			RoomId:       g.RoomID,
+			RoomName:     g.RoomName,
+			OwnerId:      g.OwnerID,
+			InviteCode:   g.InviteCode,
+			DeliveryMode: g.DeliveryMode,
+			RotateCursor: g.RotateCursor,
+			Members:      byGroupMembers[g.ID],
+			Thresholds:   byGroupThresholds[g.ID],
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+)
+
+// SnoozeRepository 学生对房间提醒的暂停记录
+type SnoozeRepository interface {
+	Save(ctx context.Context, s *domain.Snooze) error
+	FindByRoom(ctx context.Context, roomId string) ([]*domain.Snooze, error)
+	Delete(ctx context.Context, ids []int64) error
+}
+
+type snoozeRepository struct {
+	dao dao.SnoozeDAO
+}
+
+func NewSnoozeRepository(dao dao.SnoozeDAO) SnoozeRepository {
+	return &snoozeRepository{dao: dao}
+}
+
+func (r *snoozeRepository) Save(ctx context.Context, s *domain.Snooze) error {
+	return r.dao.Upsert(ctx, &model.AlertSnooze{
+		StudentID: s.StudentId,
+		RoomID:    s.RoomId,
+		Until:     s.Until,
+		Remain:    s.Remain,
+	})
+}
+
+func (r *snoozeRepository) FindByRoom(ctx context.Context, roomId string) ([]*domain.Snooze, error) {
+	ss, err := r.dao.FindByRoom(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*domain.Snooze, 0, len(ss))
+	for _, s := range ss {
+		res = append(res, &domain.Snooze{
+			ID:        s.ID,
+			StudentId: s.StudentID,
+			RoomId:    s.RoomID,
+			Until:     s.Until,
+			Remain:    s.Remain,
+		})
+	}
+	return res, nil
+}
+
+func (r *snoozeRepository) Delete(ctx context.Context, ids []int64) error {
+	return r.dao.DeleteByIDs(ctx, ids)
+}
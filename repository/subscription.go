@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository/cache"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"time"
+)
+
+// SubscriptionRepository 学生订阅的存储,按学生缓存订阅列表
+type SubscriptionRepository interface {
+	FindByStudent(ctx context.Context, studentId string) ([]*domain.Subscription, error)
+	Save(ctx context.Context, sub *domain.Subscription) error
+	Delete(ctx context.Context, studentId string, roomId string) error
+	// FindByCursor 按 id 升序分页遍历所有订阅,lastID 为 -1 时从头开始
+	FindByCursor(ctx context.Context, lastID int64, limit int) ([]*domain.Subscription, int64, error)
+	// SoftDelete 软删除指定的订阅,返回删除的数量
+	SoftDelete(ctx context.Context, subs []*domain.Subscription) (int64, error)
+	CountDeletedBefore(ctx context.Context, before time.Time) (int64, error)
+	PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error)
+	// MarkAlerted 记录阈值发出提醒的时间,用于冷却
+	MarkAlerted(ctx context.Context, thresholdIDs []int64, at int64) error
+	// MarkAnomalyAlerted 记录订阅发出用电异常提醒的时间,同一天的异常只提醒一次
+	MarkAnomalyAlerted(ctx context.Context, ids []int64, at int64) error
+}
+
+type cachedSubscriptionRepository struct {
+	dao   dao.ElecpriceDAO
+	cache cache.SubscriptionCache
+	l     logger.Logger
+}
+
+func NewCachedSubscriptionRepository(dao dao.ElecpriceDAO, cache cache.SubscriptionCache, l logger.Logger) SubscriptionRepository {
+	return &cachedSubscriptionRepository{dao: dao, cache: cache, l: l}
+}
+
+func (r *cachedSubscriptionRepository) FindByStudent(ctx context.Context, studentId string) ([]*domain.Subscription, error) {
+	subs, err := r.cache.Get(ctx, studentId)
+	if err == nil {
+		return subs, nil
+	}
+	if err != cache.ErrKeyNotExist {
+		// 缓存出错时降级查数据库
+		r.l.Warn("读取订阅缓存失败", logger.Error(err), logger.String("studentId", studentId))
+	}
+
+	configs, err := r.dao.FindAll(ctx, studentId)
+	if err != nil {
+		return nil, err
+	}
+	subs, err = r.toDomains(ctx, configs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.cache.Set(ctx, studentId, subs); err != nil {
+		r.l.Warn("写入订阅缓存失败", logger.Error(err), logger.String("studentId", studentId))
+	}
+	return subs, nil
+}
+
+func (r *cachedSubscriptionRepository) Save(ctx context.Context, sub *domain.Subscription) error {
+	err := r.dao.Upsert(ctx, sub.StudentId, sub.RoomId, r.toEntity(sub), toThresholdEntities(sub.Thresholds))
+	if err != nil {
+		return err
+	}
+	r.invalidate(ctx, sub.StudentId)
+	return nil
+}
+
+func (r *cachedSubscriptionRepository) Delete(ctx context.Context, studentId string, roomId string) error {
+	err := r.dao.Delete(ctx, studentId, roomId)
+	if err != nil {
+		return err
+	}
+	r.invalidate(ctx, studentId)
+	return nil
+}
+
+func (r *cachedSubscriptionRepository) FindByCursor(ctx context.Context, lastID int64, limit int) ([]*domain.Subscription, int64, error) {
+	configs, nextID, err := r.dao.GetConfigsByCursor(ctx, lastID, limit)
+	if err != nil {
+		return nil, -1, err
+	}
+	subs, err := r.toDomains(ctx, configs)
+	if err != nil {
+		return nil, -1, err
+	}
+	return subs, nextID, nil
+}
+
+func (r *cachedSubscriptionRepository) SoftDelete(ctx context.Context, subs []*domain.Subscription) (int64, error) {
+	ids := make([]int64, 0, len(subs))
+	studentIds := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+		studentIds = append(studentIds, sub.StudentId)
+	}
+	deleted, err := r.dao.SoftDeleteByIDs(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	r.invalidate(ctx, studentIds...)
+	return deleted, nil
+}
+
+func (r *cachedSubscriptionRepository) CountDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	return r.dao.CountDeletedBefore(ctx, before)
+}
+
+func (r *cachedSubscriptionRepository) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	// 只会删除已经软删除的数据,缓存中不会有这些订阅
+	return r.dao.PurgeDeletedBefore(ctx, before)
+}
+
+func (r *cachedSubscriptionRepository) MarkAlerted(ctx context.Context, thresholdIDs []int64, at int64) error {
+	return r.dao.UpdateThresholdAlertedAt(ctx, thresholdIDs, at)
+}
+
+func (r *cachedSubscriptionRepository) MarkAnomalyAlerted(ctx context.Context, ids []int64, at int64) error {
+	return r.dao.UpdateAnomalyAlertedAt(ctx, ids, at)
+}
+
+// invalidate 删除缓存失败只记录日志,缓存会在过期后自动恢复一致
+func (r *cachedSubscriptionRepository) invalidate(ctx context.Context, studentIds ...string) {
+	if err := r.cache.Del(ctx, studentIds...); err != nil {
+		r.l.Warn("删除订阅缓存失败", logger.Error(err), logger.Any("studentIds", studentIds))
+	}
+}
+
+// toDomains 转换配置并批量加载它们的阈值
+func (r *cachedSubscriptionRepository) toDomains(ctx context.Context, configs []model.ElecpriceConfig) ([]*domain.Subscription, error) {
+	ids := make([]int64, 0, len(configs))
+	for _, c := range configs {
+		ids = append(ids, c.ID)
+	}
+	thresholds, err := r.dao.FindThresholds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byConfig := make(map[int64][]*domain.Threshold, len(configs))
+	for _, t := range thresholds {
+		byConfig[t.ConfigID] = append(byConfig[t.ConfigID], &domain.Threshold{
+			ID:          t.ID,
+			Limit:       t.Limit,
+			Severity:    t.Severity,
+			Template:    t.Template,
+			Cooldown:    t.Cooldown,
+			LastAlertAt: t.LastAlertAt,
+		})
+	}
+
+	subs := make([]*domain.Subscription, 0, len(configs))
+	for i := range configs {
+		sub := r.toDomain(&configs[i])
+		sub.Thresholds = byConfig[sub.ID]
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (r *cachedSubscriptionRepository) toDomain(c *model.ElecpriceConfig) *domain.Subscription {
+	return &domain.Subscription{
+		ID:        c.ID,
+		StudentId: c.StudentID,
+		RoomId:    c.TargetID,
+		RoomName:  c.RoomName,
+		Limit:     c.Limit,
+
+		AnomalyAlert:     c.AnomalyAlert,
+		AnomalyZScore:    c.AnomalyZScore,
+		AnomalyAlertedAt: c.AnomalyAlertedAt,
+	}
+}
+
+func (r *cachedSubscriptionRepository) toEntity(sub *domain.Subscription) *model.ElecpriceConfig {
+	return &model.ElecpriceConfig{
+		StudentID: sub.StudentId,
+		Limit:     sub.Limit,
+		TargetID:  sub.RoomId,
+		RoomName:  sub.RoomName,
+
+		AnomalyAlert:  sub.AnomalyAlert,
+		AnomalyZScore: sub.AnomalyZScore,
+	}
+}
+
+// toThresholdEntities 订阅和房间群组共用的阈值转换,所属的订阅或群组在保存时填充
+func toThresholdEntities(thresholds []*domain.Threshold) []model.ElecpriceThreshold {
+	res := make([]model.ElecpriceThreshold, 0, len(thresholds))
+	for _, t := range thresholds {
+		res = append(res, model.ElecpriceThreshold{
+			Limit:    t.Limit,
+			Severity: t.Severity,
+			Template: t.Template,
+			Cooldown: t.Cooldown,
+		})
+	}
+	return res
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/repository"
+	"time"
+)
+
+var (
+	FIND_ALERT_HISTORY_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorFindAlertHistoryError("获取提醒历史失败"), "dao", err)
+	}
+)
+
+type AlertHistoryService interface {
+	// Record 保存一条提醒记录,成功后回填 ID
+	Record(ctx context.Context, r *domain.AlertRecord) error
+	// UpdateStatus 推迟的提醒发送后更新记录的状态
+	UpdateStatus(ctx context.Context, id int64, channel string, status string, feedResponse string) error
+	ListAlertHistory(ctx context.Context, r *domain.ListAlertHistoryRequest) (*domain.ListAlertHistoryResponse, error)
+}
+
+type alertHistoryService struct {
+	repo repository.AlertHistoryRepository
+}
+
+func NewAlertHistoryService(repo repository.AlertHistoryRepository) AlertHistoryService {
+	return &alertHistoryService{repo: repo}
+}
+
+func (s *alertHistoryService) Record(ctx context.Context, r *domain.AlertRecord) error {
+	return s.repo.Create(ctx, r)
+}
+
+func (s *alertHistoryService) UpdateStatus(ctx context.Context, id int64, channel string, status string, feedResponse string) error {
+	return s.repo.UpdateStatus(ctx, id, channel, status, feedResponse, time.Now().Unix())
+}
+
+func (s *alertHistoryService) ListAlertHistory(ctx context.Context, r *domain.ListAlertHistoryRequest) (*domain.ListAlertHistoryResponse, error) {
+	limit := r.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	records, err := s.repo.ListByStudent(ctx, r.StudentId, r.Offset, limit)
+	if err != nil {
+		return nil, FIND_ALERT_HISTORY_ERROR(err)
+	}
+	return &domain.ListAlertHistoryResponse{Records: records}, nil
+}
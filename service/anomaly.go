@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/service/usage"
+	"time"
+)
+
+// validateAnomaly 标准分数为 0 时使用默认值,否则限制在 [1, 10]
+func validateAnomaly(standard *domain.Standard) error {
+	if standard.AnomalyZScore != 0 && (standard.AnomalyZScore < 1 || standard.AnomalyZScore > 10) {
+		return errors.New("用电异常的标准分数应在 1 到 10 之间")
+	}
+	return nil
+}
+
+// detectAnomaly 检测房间最近一天的用电是否异常,不异常时返回 nil
+// 标准分数超过 minZScore 才返回,每个订阅再按自己的灵敏度判断
+func (s *elecpriceService) detectAnomaly(ctx context.Context, roomId string, minZScore float64, now time.Time) (*domain.Anomaly, error) {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		loc = time.Local
+	}
+	from := now.AddDate(0, 0, -(s.anomalyCfg.Window + 2))
+	readings, err := s.readingRepo.FindRange(ctx, roomId, from.Unix(), now.Unix()+1)
+	if err != nil {
+		return nil, err
+	}
+
+	days, usages := usage.DailyUsages(readings, loc)
+	// 最近一天的数据太旧说明读数中断了,不再提醒
+	if len(days) == 0 || days[len(days)-1] < now.In(loc).AddDate(0, 0, -2).Format(time.DateOnly) {
+		return nil, nil
+	}
+	mean, std, z, ok := usage.ScoreAnomaly(usages, s.anomalyCfg)
+	if !ok || z < minZScore {
+		return nil, nil
+	}
+	return &domain.Anomaly{
+		Day:    days[len(days)-1],
+		Usage:  usages[len(usages)-1],
+		Mean:   roundMoney(mean),
+		Std:    roundMoney(std),
+		ZScore: z,
+	}, nil
+}
+
+// anomalyZScore 订阅判定异常使用的标准分数
+func (s *elecpriceService) anomalyZScore(sub *domain.Subscription) float64 {
+	if sub.AnomalyZScore > 0 {
+		return sub.AnomalyZScore
+	}
+	return s.anomalyCfg.ZScore
+}
+
+// anomalyAlerted 订阅在这次异常之后是否已经提醒过,异常日期的用电在第二天才能读到
+func anomalyAlerted(sub *domain.Subscription, a *domain.Anomaly) bool {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		loc = time.Local
+	}
+	day, err := time.ParseInLocation(time.DateOnly, a.Day, loc)
+	if err != nil {
+		return false
+	}
+	return sub.AnomalyAlertedAt >= day.AddDate(0, 0, 1).Unix()
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"sync"
+)
+
+var (
+	INVALID_BATCH_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidBatchError("批量查询的参数不合法"), "param", err)
+	}
+)
+
+// BatchPriceConfig 批量查询电费的参数
+type BatchPriceConfig struct {
+	MaxRooms    int `yaml:"maxRooms"`    // 一次最多查询的房间数
+	Concurrency int `yaml:"concurrency"` // 同时请求学校接口的房间数
+}
+
+func (s *elecpriceService) BatchGetPrice(ctx context.Context, roomIds []string) ([]*domain.RoomPrice, error) {
+	// 重复的房间只查询一次
+	res := make([]*domain.RoomPrice, 0, len(roomIds))
+	seen := make(map[string]bool, len(roomIds))
+	for _, roomId := range roomIds {
+		if roomId == "" || seen[roomId] {
+			continue
+		}
+		seen[roomId] = true
+		res = append(res, &domain.RoomPrice{RoomId: roomId})
+	}
+	if len(res) == 0 {
+		return nil, INVALID_BATCH_ERROR(fmt.Errorf("没有指定房间"))
+	}
+	if len(res) > s.batchCfg.MaxRooms {
+		return nil, INVALID_BATCH_ERROR(fmt.Errorf("一次最多查询 %d 个房间", s.batchCfg.MaxRooms))
+	}
+
+	concurrency := s.batchCfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, concurrency)
+	)
+	for _, r := range res {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		// 每个 goroutine 只写自己的结果,不需要加锁
+		go func(r *domain.RoomPrice) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			r.Price, r.Err = s.GetPrice(ctx, r.RoomId)
+		}(r)
+	}
+	wg.Wait()
+	return res, nil
+}
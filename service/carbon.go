@@ -0,0 +1,40 @@
+package service
+
+import (
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/spf13/viper"
+	"math"
+)
+
+// CarbonConfig 碳排放估算的参数
+type CarbonConfig struct {
+	EmissionFactor float64 `yaml:"emissionFactor"` // 电网排放因子,每度电对应的 CO2 千克数
+	TreeAbsorption float64 `yaml:"treeAbsorption"` // 一棵树一年吸收的 CO2 千克数
+}
+
+// CarbonEstimator 把用电量换算为碳排放
+type CarbonEstimator interface {
+	Estimate(kwh float64) domain.Carbon
+}
+
+type carbonEstimator struct {
+	cfg CarbonConfig
+}
+
+// NewCarbonEstimator 默认使用全国电网平均排放因子
+func NewCarbonEstimator() CarbonEstimator {
+	cfg := CarbonConfig{EmissionFactor: 0.5703, TreeAbsorption: 18.3}
+	if err := viper.UnmarshalKey("carbon", &cfg); err != nil {
+		panic(err)
+	}
+	return &carbonEstimator{cfg: cfg}
+}
+
+func (e *carbonEstimator) Estimate(kwh float64) domain.Carbon {
+	kg := math.Max(kwh, 0) * e.cfg.EmissionFactor
+	res := domain.Carbon{Kg: roundMoney(kg)}
+	if e.cfg.TreeAbsorption > 0 {
+		res.Trees = roundMoney(kg / e.cfg.TreeAbsorption)
+	}
+	return res
+}
@@ -9,8 +9,10 @@ import (
 	"github.com/asynccnu/be-elecprice/domain"
 	"github.com/asynccnu/be-elecprice/pkg/errorx"
 	"github.com/asynccnu/be-elecprice/pkg/logger"
-	"github.com/asynccnu/be-elecprice/repository/dao"
-	"github.com/asynccnu/be-elecprice/repository/model"
+	"github.com/asynccnu/be-elecprice/repository"
+	"github.com/asynccnu/be-elecprice/service/usage"
+	"github.com/spf13/viper"
+	"math"
 	"net/url"
 	"strconv"
 	"sync"
@@ -27,41 +29,128 @@ var (
 	SAVE_CONFIG_ERROR = func(err error) error {
 		return errorx.New(elecpricev1.ErrorSaveConfigError("保存配置失败"), "dao", err)
 	}
+	INVALID_STANDARD_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidStandardError("提醒阈值设置不合法"), "param", err)
+	}
+	STANDARD_NOT_FOUND_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorStandardNotFoundError("没有订阅该房间"), "param", err)
+	}
+	INVALID_SNOOZE_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidSnoozeError("暂停时间不合法"), "param", err)
+	}
 )
 
 type ElecpriceService interface {
 	SetStandard(ctx context.Context, r *domain.SetStandardRequest) error
 	GetStandardList(ctx context.Context, r *domain.GetStandardListRequest) (*domain.GetStandardListResponse, error)
 	CancelStandard(ctx context.Context, r *domain.CancelStandardRequest) error
-	GetTobePushMSG(ctx context.Context) ([]*domain.ElectricMSG, error)
+	// AcknowledgeAlert 确认提醒,电费继续下降超过设定幅度前不再提醒
+	AcknowledgeAlert(ctx context.Context, r *domain.AcknowledgeAlertRequest) error
+	// SnoozeStandard 暂停提醒直到 Until,期间电费继续下降超过设定幅度时提前恢复
+	SnoozeStandard(ctx context.Context, r *domain.SnoozeStandardRequest) error
+	GetTobePushMSG(ctx context.Context) (*domain.ElectricMSGBatch, error)
+	// MarkAlerted 记录阈值已经发出提醒,冷却期内不再重复提醒
+	// rotations 为发出提醒的群组下一次轮流提醒的成员序号,key 为群组 ID
+	MarkAlerted(ctx context.Context, thresholdIDs []int64, rotations map[int64]int64) error
+	// MarkAnomalyAlerted 记录订阅已经发出用电异常提醒
+	MarkAnomalyAlerted(ctx context.Context, subscriptionIDs []int64) error
 
 	GetArchitecture(ctx context.Context, area string) (domain.ResultArchitectureInfo, error)
 	GetRoomInfo(ctx context.Context, archiID string, floor string) (map[string]string, error)
 	GetPrice(ctx context.Context, roomid string) (*domain.Prices, error)
+	// BatchGetPrice 并发查询多个房间的电费,按请求的顺序返回,单个房间失败只记录在该房间的结果中
+	BatchGetPrice(ctx context.Context, roomIds []string) ([]*domain.RoomPrice, error)
+	// SubscribedRooms 返回被个人订阅或房间群组订阅的所有房间
+	SubscribedRooms(ctx context.Context) ([]string, error)
 }
 
 type elecpriceService struct {
-	elecpriceDAO dao.ElecpriceDAO
-	l            logger.Logger
+	subscriptionRepo repository.SubscriptionRepository
+	readingRepo      repository.ReadingRepository
+	catalogRepo      repository.CatalogRepository
+	snoozeRepo       repository.SnoozeRepository
+	groupRepo        repository.RoomGroupRepository
+	rechargeRepo     repository.RechargeRepository
+	carbon           CarbonEstimator
+	snoozeCfg        SnoozeConfig
+	anomalyCfg       usage.AnomalyConfig
+	samplerCfg       SamplerConfig
+	batchCfg         BatchPriceConfig
+	l                logger.Logger
 }
 
-func NewElecpriceService(elecpriceDAO dao.ElecpriceDAO, l logger.Logger) ElecpriceService {
-	return &elecpriceService{elecpriceDAO: elecpriceDAO, l: l}
+type SnoozeConfig struct {
+	Margin float64 `yaml:"margin"` // 暂停后电费继续下降超过该金额时恢复提醒,单位元
+}
+
+func NewElecpriceService(
+	subscriptionRepo repository.SubscriptionRepository,
+	readingRepo repository.ReadingRepository,
+	catalogRepo repository.CatalogRepository,
+	snoozeRepo repository.SnoozeRepository,
+	groupRepo repository.RoomGroupRepository,
+	rechargeRepo repository.RechargeRepository,
+	carbon CarbonEstimator,
+	l logger.Logger,
+) ElecpriceService {
+	cfg := SnoozeConfig{Margin: 5}
+	if err := viper.UnmarshalKey("snooze", &cfg); err != nil {
+		panic(err)
+	}
+	batchCfg := BatchPriceConfig{MaxRooms: 20, Concurrency: 5}
+	if err := viper.UnmarshalKey("batchPrice", &batchCfg); err != nil {
+		panic(err)
+	}
+	anomalyCfg := usage.DefaultAnomalyConfig
+	if err := viper.UnmarshalKey("anomaly", &anomalyCfg); err != nil {
+		panic(err)
+	}
+	return &elecpriceService{
+		subscriptionRepo: subscriptionRepo,
+		readingRepo:      readingRepo,
+		catalogRepo:      catalogRepo,
+		snoozeRepo:       snoozeRepo,
+		groupRepo:        groupRepo,
+		rechargeRepo:     rechargeRepo,
+		carbon:           carbon,
+		snoozeCfg:        cfg,
+		anomalyCfg:       anomalyCfg,
+		samplerCfg:       LoadSamplerConfig(),
+		batchCfg:         batchCfg,
+		l:                l,
+	}
 }
 
 func (s *elecpriceService) SetStandard(ctx context.Context, r *domain.SetStandardRequest) error {
-	conf := &model.ElecpriceConfig{
-		StudentID: r.StudentId,
-		Limit:     r.Standard.Limit,
-		RoomName:  r.Standard.RoomName,
-		TargetID:  r.Standard.RoomId,
+	if err := validateThresholds(r.Standard.Thresholds); err != nil {
+		return INVALID_STANDARD_ERROR(err)
+	}
+	if err := validateAnomaly(r.Standard); err != nil {
+		return INVALID_STANDARD_ERROR(err)
 	}
 
-	return s.elecpriceDAO.Upsert(ctx, r.StudentId, r.Standard.RoomId, conf)
+	// 旧版客户端只读 Limit,取最高的阈值
+	limit := r.Standard.Limit
+	for _, t := range r.Standard.Thresholds {
+		if t.Limit > limit {
+			limit = t.Limit
+		}
+	}
+
+	return s.subscriptionRepo.Save(ctx, &domain.Subscription{
+		StudentId:  r.StudentId,
+		RoomId:     r.Standard.RoomId,
+		RoomName:   r.Standard.RoomName,
+		Limit:      limit,
+		Thresholds: r.Standard.Thresholds,
+
+		AnomalyAlert:  r.Standard.AnomalyAlert,
+		AnomalyZScore: r.Standard.AnomalyZScore,
+	})
 }
 
 func (s *elecpriceService) GetStandardList(ctx context.Context, r *domain.GetStandardListRequest) (*domain.GetStandardListResponse, error) {
-	res, err := s.elecpriceDAO.FindAll(ctx, r.StudentId)
+	res, err := s.subscriptionRepo.FindByStudent(ctx, r.StudentId)
 	if err != nil {
 		return nil, FIND_CONFIG_ERROR(err)
 	}
@@ -69,9 +158,13 @@ func (s *elecpriceService) GetStandardList(ctx context.Context, r *domain.GetSta
 	var standards []*domain.Standard
 	for _, r := range res {
 		standards = append(standards, &domain.Standard{
-			Limit:    r.Limit,
-			RoomId:   r.TargetID,
-			RoomName: r.RoomName,
+			Limit:      r.Limit,
+			RoomId:     r.RoomId,
+			RoomName:   r.RoomName,
+			Thresholds: r.Thresholds,
+
+			AnomalyAlert:  r.AnomalyAlert,
+			AnomalyZScore: r.AnomalyZScore,
 		})
 	}
 
@@ -79,23 +172,21 @@ func (s *elecpriceService) GetStandardList(ctx context.Context, r *domain.GetSta
 }
 
 func (s *elecpriceService) CancelStandard(ctx context.Context, r *domain.CancelStandardRequest) error {
-	return s.elecpriceDAO.Delete(ctx, r.StudentId, r.RoomId)
+	return s.subscriptionRepo.Delete(ctx, r.StudentId, r.RoomId)
 }
 
-func (s *elecpriceService) GetTobePushMSG(ctx context.Context) ([]*domain.ElectricMSG, error) {
+func (s *elecpriceService) GetTobePushMSG(ctx context.Context) (*domain.ElectricMSGBatch, error) {
 	var (
-		resultMsgs []*domain.ElectricMSG       // 存储最终结果
-		lastID     int64                 = -1  // 初始游标为 -1，表示从头开始
-		limit      int                   = 100 // 每次分页查询的大小
+		result       = &domain.ElectricMSGBatch{} // 存储最终结果
+		lastID int64 = -1                         // 初始游标为 -1，表示从头开始
+		limit  int   = 100                        // 每次分页查询的大小
 	)
 
-	// 用于控制并发量的通道（令牌池），限制同时运行的 goroutine 数量为 10
-	maxConcurrency := 10
-	semaphore := make(chan struct{}, maxConcurrency)
-
+	// 同一个房间可能被多个学生(室友)订阅,先按房间分组,保证每个房间只爬取一次
+	roomConfigs := make(map[string][]*domain.Subscription)
 	for {
 		// 分页获取配置数据
-		configs, nextID, err := s.elecpriceDAO.GetConfigsByCursor(ctx, lastID, limit)
+		configs, nextID, err := s.subscriptionRepo.FindByCursor(ctx, lastID, limit)
 		if err != nil {
 			return nil, err
 		}
@@ -105,75 +196,213 @@ func (s *elecpriceService) GetTobePushMSG(ctx context.Context) ([]*domain.Electr
 			break
 		}
 
-		// 用于并发处理的 goroutine
-		var (
-			wg      sync.WaitGroup
-			mu      sync.Mutex
-			errChan = make(chan error, len(configs))
-		)
-
 		for _, config := range configs {
-			wg.Add(1)
-			// 获取一个令牌（阻塞直到可用）
-			semaphore <- struct{}{}
+			roomConfigs[config.RoomId] = append(roomConfigs[config.RoomId], config)
+		}
 
-			go func(cfg model.ElecpriceConfig) {
-				defer wg.Done()
-				// 释放令牌
-				defer func() { <-semaphore }()
+		// 更新游标
+		lastID = nextID
+	}
 
-				// 获取房间的实时电费
-				elecPrice, err := s.GetPrice(ctx, cfg.TargetID)
+	// 房间群组同样按房间分组,和个人订阅共用一次爬取
+	roomGroups := make(map[string][]*domain.RoomGroup)
+	lastID = -1
+	for {
+		groups, nextID, err := s.groupRepo.FindByCursor(ctx, lastID, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) == 0 {
+			break
+		}
+		for _, g := range groups {
+			roomGroups[g.RoomId] = append(roomGroups[g.RoomId], g)
+		}
+		lastID = nextID
+	}
 
-				if err != nil {
-					errChan <- err
-					return
-				}
+	rooms := make(map[string]struct{}, len(roomConfigs)+len(roomGroups))
+	for roomID := range roomConfigs {
+		rooms[roomID] = struct{}{}
+	}
+	for roomID := range roomGroups {
+		rooms[roomID] = struct{}{}
+	}
+
+	// 用于控制并发量的通道（令牌池），限制同时运行的 goroutine 数量为 10
+	maxConcurrency := 10
+	semaphore := make(chan struct{}, maxConcurrency)
+
+	// 用于并发处理的 goroutine
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	result.CheckedRooms = int64(len(rooms))
+
+	for roomID := range rooms {
+		wg.Add(1)
+		// 获取一个令牌（阻塞直到可用）
+		semaphore <- struct{}{}
 
-				// 转换电费数据为浮点数
-				Remain, err := strconv.ParseFloat(elecPrice.RemainMoney, 64)
+		go func(roomID string, cfgs []*domain.Subscription, groups []*domain.RoomGroup) {
+			defer wg.Done()
+			// 释放令牌
+			defer func() { <-semaphore }()
 
-				// 跳过解析失败的数据
+			// 获取房间的电费,开启后台采样时优先使用采样到的读数,每个房间最多请求一次
+			remainMoney, err := s.currentRemain(ctx, roomID, time.Now())
+			if err != nil {
+				mu.Lock()
+				result.Errs = append(result.Errs, err)
+				mu.Unlock()
+				return
+			}
+
+			// 转换电费数据为浮点数
+			Remain, err := strconv.ParseFloat(remainMoney, 64)
+
+			// 跳过解析失败的数据
+			if err != nil {
+				mu.Lock()
+				result.Errs = append(result.Errs, fmt.Errorf("解析电费数据失败: %v", err))
+				mu.Unlock()
+				return
+			}
+
+			snoozed, err := s.activeSnoozes(ctx, roomID, Remain)
+			if err != nil {
+				// 读取失败时照常提醒,宁可多提醒也不要漏掉
+				mu.Lock()
+				result.Errs = append(result.Errs, err)
+				mu.Unlock()
+			}
+
+			// 开启了用电异常提醒的订阅共用一次检测,按其中最灵敏的订阅检测
+			var anomaly *domain.Anomaly
+			minZScore := math.Inf(1)
+			for i := range cfgs {
+				if cfgs[i].AnomalyAlert {
+					minZScore = math.Min(minZScore, s.anomalyZScore(cfgs[i]))
+				}
+			}
+			if !math.IsInf(minZScore, 1) {
+				anomaly, err = s.detectAnomaly(ctx, roomID, minZScore, time.Now())
 				if err != nil {
-					errChan <- fmt.Errorf("解析电费数据失败: %v", err)
-					return
+					mu.Lock()
+					result.Errs = append(result.Errs, err)
+					mu.Unlock()
 				}
+			}
 
-				// 检查是否符合用户设定的阈值
-				if Remain < float64(cfg.Limit) {
+			// 将结果分发给订阅了该房间的所有学生
+			now := time.Now().Unix()
+			for i := range cfgs {
+				// 用电异常提醒和电费不足提醒相互独立,不受暂停提醒影响
+				if anomaly != nil && cfgs[i].AnomalyAlert && anomaly.ZScore >= s.anomalyZScore(cfgs[i]) && !anomalyAlerted(cfgs[i], anomaly) {
 					msg := &domain.ElectricMSG{
-						RoomName:  &cfg.RoomName,
-						StudentId: cfg.StudentID,
-						Remain:    &elecPrice.RemainMoney,
+						RoomId:         roomID,
+						RoomName:       &cfgs[i].RoomName,
+						StudentId:      cfgs[i].StudentId,
+						Remain:         &remainMoney,
+						Severity:       domain.SeverityAnomaly,
+						Anomaly:        anomaly,
+						SubscriptionID: cfgs[i].ID,
 					}
-
-					// 并发安全地添加结果
 					mu.Lock()
-					resultMsgs = append(resultMsgs, msg)
+					result.MSGs = append(result.MSGs, msg)
 					mu.Unlock()
 				}
-			}(config)
-		}
 
-		// 等待所有 goroutine 完成
-		wg.Wait()
-		close(errChan)
+				if snoozed[cfgs[i].StudentId] {
+					continue
+				}
+				// 检查是否符合用户设定的阈值,多个阈值只提醒最严重的一级
+				t := pickThreshold(cfgs[i], Remain, now)
+				if t == nil {
+					continue
+				}
+				msg := &domain.ElectricMSG{
+					RoomId:      roomID,
+					RoomName:    &cfgs[i].RoomName,
+					StudentId:   cfgs[i].StudentId,
+					Remain:      &remainMoney,
+					Limit:       t.Limit,
+					Severity:    t.Severity,
+					Template:    t.Template,
+					ThresholdID: t.ID,
+				}
 
-		// 检查是否有错误
-		for err := range errChan {
-			if err != nil {
-				// 可以选择返回第一个错误，或者记录日志
-				return nil, err
+				// 并发安全地添加结果
+				mu.Lock()
+				result.MSGs = append(result.MSGs, msg)
+				mu.Unlock()
 			}
-		}
 
-		// 更新游标
-		lastID = nextID
+			// 自己也订阅了该房间的成员只按个人订阅提醒
+			personal := make(map[string]bool, len(cfgs))
+			for i := range cfgs {
+				personal[cfgs[i].StudentId] = true
+			}
+			for _, g := range groups {
+				t := pickThreshold(&domain.Subscription{Thresholds: g.Thresholds}, Remain, now)
+				if t == nil {
+					continue
+				}
+				recipients, next := groupRecipients(g, func(studentId string) bool {
+					return personal[studentId] || snoozed[studentId]
+				})
+				for _, studentId := range recipients {
+					msg := &domain.ElectricMSG{
+						RoomId:      roomID,
+						RoomName:    &g.RoomName,
+						StudentId:   studentId,
+						Remain:      &remainMoney,
+						Limit:       t.Limit,
+						Severity:    t.Severity,
+						Template:    t.Template,
+						ThresholdID: t.ID,
+						GroupId:     g.ID,
+						NextRotate:  next,
+					}
+					mu.Lock()
+					result.MSGs = append(result.MSGs, msg)
+					mu.Unlock()
+				}
+			}
+		}(roomID, roomConfigs[roomID], roomGroups[roomID])
+	}
+
+	// 等待所有 goroutine 完成,单个房间失败不影响其他房间的提醒
+	wg.Wait()
+
+	return result, nil
+}
+
+func (s *elecpriceService) MarkAlerted(ctx context.Context, thresholdIDs []int64, rotations map[int64]int64) error {
+	err := s.subscriptionRepo.MarkAlerted(ctx, thresholdIDs, time.Now().Unix())
+	if err != nil {
+		return SAVE_CONFIG_ERROR(err)
 	}
-	return resultMsgs, nil
+	// 群组的阈值提醒后轮到实际提醒的成员之后的下一位
+	if err := s.groupRepo.UpdateRotation(ctx, rotations); err != nil {
+		return SAVE_GROUP_ERROR(err)
+	}
+	return nil
+}
+
+func (s *elecpriceService) MarkAnomalyAlerted(ctx context.Context, subscriptionIDs []int64) error {
+	if err := s.subscriptionRepo.MarkAnomalyAlerted(ctx, subscriptionIDs, time.Now().Unix()); err != nil {
+		return SAVE_CONFIG_ERROR(err)
+	}
+	return nil
 }
 
 func (s *elecpriceService) GetArchitecture(ctx context.Context, area string) (domain.ResultArchitectureInfo, error) {
+	if cached, err := s.catalogRepo.FindArchitecture(ctx, area); err == nil {
+		return cached, nil
+	}
+
 	for name_, code := range ConstantMap {
 		if area == name_ {
 			body, err := sendRequest(ctx, fmt.Sprintf("https://jnb.ccnu.edu.cn/ICBS/PurchaseWebService.asmx/getArchitectureInfo?Area_ID=%s", code))
@@ -181,11 +410,14 @@ func (s *elecpriceService) GetArchitecture(ctx context.Context, area string) (do
 				return domain.ResultArchitectureInfo{}, INTERNET_ERROR(err)
 			}
 			var result domain.ResultArchitectureInfo
-			
+
 			err = xml.Unmarshal([]byte(body), &result)
 			if err != nil {
 				return domain.ResultArchitectureInfo{}, INTERNET_ERROR(err)
 			}
+			if err := s.catalogRepo.SaveArchitecture(ctx, area, result); err != nil {
+				s.l.Warn("缓存楼栋信息失败", logger.Error(err), logger.String("area", area))
+			}
 			return result, nil
 
 		}
@@ -194,6 +426,10 @@ func (s *elecpriceService) GetArchitecture(ctx context.Context, area string) (do
 }
 
 func (s *elecpriceService) GetRoomInfo(ctx context.Context, archiID string, floor string) (map[string]string, error) {
+	if cached, err := s.catalogRepo.FindRoomInfo(ctx, archiID, floor); err == nil {
+		return cached, nil
+	}
+
 	body, err := sendRequest(ctx, fmt.Sprintf("https://jnb.ccnu.edu.cn/ICBS/PurchaseWebService.asmx/getRoomInfo?Architecture_ID=%s&Floor=%s", archiID, floor))
 	if err != nil {
 		return nil, INTERNET_ERROR(err)
@@ -204,6 +440,9 @@ func (s *elecpriceService) GetRoomInfo(ctx context.Context, archiID string, floo
 	if err != nil {
 		return nil, INTERNET_ERROR(err)
 	}
+	if err := s.catalogRepo.SaveRoomInfo(ctx, archiID, floor, res); err != nil {
+		s.l.Warn("缓存房间信息失败", logger.Error(err), logger.String("architectureID", archiID))
+	}
 
 	return res, nil
 }
@@ -218,11 +457,75 @@ func (s *elecpriceService) GetPrice(ctx context.Context, roomid string) (*domain
 	if err != nil {
 		return nil, INTERNET_ERROR(err)
 	}
+	s.saveReading(ctx, roomid, price)
+
+	// 昨日用电可能为空,解析失败时记为 0
+	useValue, _ := strconv.ParseFloat(price.YesterdayUseValue, 64)
+	price.YesterdayCarbon = s.carbon.Estimate(useValue)
 
 	return price, nil
 }
 
+// saveReading 记录每次查询到的读数,用于之后的统计,失败不影响查询结果
+func (s *elecpriceService) saveReading(ctx context.Context, roomid string, price *domain.Prices) {
+	remain, err := strconv.ParseFloat(price.RemainMoney, 64)
+	if err != nil {
+		return
+	}
+	// 昨日用电可能为空,解析失败时记为 0
+	useValue, _ := strconv.ParseFloat(price.YesterdayUseValue, 64)
+	useMoney, _ := strconv.ParseFloat(price.YesterdayUseMoney, 64)
+	now := time.Now().Unix()
+
+	// 余额比上一次读数高说明期间有人充值
+	if prev, err := s.readingRepo.FindLatest(ctx, roomid); err == nil && remain-prev.RemainMoney >= minRechargeAmount {
+		err := s.rechargeRepo.Save(ctx, &domain.Recharge{
+			RoomId:     roomid,
+			Amount:     roundMoney(remain - prev.RemainMoney),
+			Before:     prev.RemainMoney,
+			After:      remain,
+			DetectedAt: now,
+			ReadingId:  prev.ID,
+		})
+		if err != nil {
+			s.l.Warn("保存充值记录失败", logger.Error(err), logger.String("roomId", roomid))
+		}
+	}
+
+	err = s.readingRepo.Save(ctx, &domain.Reading{
+		RoomId:            roomid,
+		RemainMoney:       remain,
+		YesterdayUseValue: useValue,
+		YesterdayUseMoney: useMoney,
+		ReadAt:            now,
+	})
+	if err != nil {
+		s.l.Warn("保存电费读数失败", logger.Error(err), logger.String("roomId", roomid))
+	}
+}
+
 func (s *elecpriceService) GetMeterID(ctx context.Context, RoomID string) (string, error) {
+	return cachedMeterID(ctx, s.catalogRepo, s.l, RoomID)
+}
+
+// cachedMeterID 房间对应的电表几乎不会变化,缓存后每次查询电费可以少请求一次学校接口
+func cachedMeterID(ctx context.Context, catalogRepo repository.CatalogRepository, l logger.Logger, roomID string) (string, error) {
+	if cached, err := catalogRepo.FindMeterID(ctx, roomID); err == nil && cached != "" {
+		return cached, nil
+	}
+
+	id, err := fetchMeterID(ctx, roomID)
+	if err != nil {
+		return "", err
+	}
+	if err := catalogRepo.SaveMeterID(ctx, roomID, id); err != nil {
+		l.Warn("缓存电表信息失败", logger.Error(err), logger.String("roomId", roomID))
+	}
+	return id, nil
+}
+
+// fetchMeterID 查询房间对应的电表
+func fetchMeterID(ctx context.Context, RoomID string) (string, error) {
 	body, err := sendRequest(ctx, fmt.Sprintf("https://jnb.ccnu.edu.cn/ICBS/PurchaseWebService.asmx/getRoomMeterInfo?Room_ID=%s", RoomID))
 	if err != nil {
 		return "", INTERNET_ERROR(err)
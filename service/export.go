@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/repository"
+	"github.com/asynccnu/be-elecprice/service/usage"
+	"strconv"
+	"time"
+)
+
+var (
+	INVALID_EXPORT_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidExportError("导出参数不合法"), "param", err)
+	}
+)
+
+const (
+	// exportChunkDays 每次查询和发送的天数
+	exportChunkDays = 31
+	// maxExportDays 一次最多导出的天数
+	maxExportDays = 3 * 366
+)
+
+// ExportService 导出房间的历史用电记录
+type ExportService interface {
+	// ExportUsage 按天导出 [From, To] 的用电记录,数据分块交给 emit,CSV 的第一块包含表头
+	ExportUsage(ctx context.Context, r *domain.ExportUsageRequest, emit func(chunk []byte) error) error
+}
+
+type exportService struct {
+	subscriptionRepo repository.SubscriptionRepository
+	groupRepo        repository.RoomGroupRepository
+	readingRepo      repository.ReadingRepository
+}
+
+func NewExportService(
+	subscriptionRepo repository.SubscriptionRepository,
+	groupRepo repository.RoomGroupRepository,
+	readingRepo repository.ReadingRepository,
+) ExportService {
+	return &exportService{subscriptionRepo: subscriptionRepo, groupRepo: groupRepo, readingRepo: readingRepo}
+}
+
+func (s *exportService) ExportUsage(ctx context.Context, r *domain.ExportUsageRequest, emit func(chunk []byte) error) error {
+	if _, err := subscribedRoomName(ctx, s.subscriptionRepo, s.groupRepo, r.StudentId, r.RoomId); err != nil {
+		return err
+	}
+
+	format := r.Format
+	if format == "" {
+		format = domain.ExportFormatCSV
+	}
+	if format != domain.ExportFormatCSV && format != domain.ExportFormatJSON {
+		return INVALID_EXPORT_ERROR(fmt.Errorf("不支持的导出格式: %s", r.Format))
+	}
+	from, to, err := parseExportRange(r.From, r.To, time.Now())
+	if err != nil {
+		return INVALID_EXPORT_ERROR(err)
+	}
+
+	if format == domain.ExportFormatCSV {
+		if err := emit([]byte("date,kwh,money,balance\n")); err != nil {
+			return err
+		}
+	}
+
+	loc := from.Location()
+	for start := from; !start.After(to); start = start.AddDate(0, 0, exportChunkDays) {
+		end := start.AddDate(0, 0, exportChunkDays-1)
+		if end.After(to) {
+			end = to
+		}
+		// 某一天的用电在第二天的读数中才能读到
+		readings, err := s.readingRepo.FindRange(ctx, r.RoomId, start.AddDate(0, 0, 1).Unix(), end.AddDate(0, 0, 2).Unix())
+		if err != nil {
+			return FIND_CONFIG_ERROR(err)
+		}
+		records := usage.DailyRecords(readings, loc)
+		if len(records) == 0 {
+			continue
+		}
+
+		chunk, err := encodeRecords(records, format)
+		if err != nil {
+			return err
+		}
+		if err := emit(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseExportRange 解析导出的日期范围,返回的两个日期都包含在内
+func parseExportRange(fromStr string, toStr string, now time.Time) (time.Time, time.Time, error) {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	n := now.In(loc)
+	to := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	if toStr != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, toStr, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("结束日期格式应为 YYYY-MM-DD: %s", toStr)
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if fromStr != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, fromStr, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("开始日期格式应为 YYYY-MM-DD: %s", fromStr)
+		}
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("开始日期 %s 晚于结束日期 %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+	if to.Sub(from) > maxExportDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("一次最多导出 %d 天", maxExportDays)
+	}
+	return from, to, nil
+}
+
+func encodeRecords(records []*domain.UsageRecord, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	if format == domain.ExportFormatJSON {
+		enc := json.NewEncoder(&buf)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	w := csv.NewWriter(&buf)
+	for _, r := range records {
+		err := w.Write([]string{
+			r.Date,
+			strconv.FormatFloat(r.Value, 'f', -1, 64),
+			strconv.FormatFloat(r.Money, 'f', -1, 64),
+			strconv.FormatFloat(r.Balance, 'f', -1, 64),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/model"
+	"sync"
+	"time"
+)
+
+var (
+	JOB_NOT_FOUND_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorJobNotFoundError("任务不存在"), "job", err)
+	}
+	JOB_RUNNING_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorJobRunningError("任务正在执行"), "job", err)
+	}
+	FIND_JOB_RUN_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorFindJobRunError("获取任务记录失败"), "dao", err)
+	}
+	JOB_CLOSED_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorJobClosedError("服务正在关闭"), "job", err)
+	}
+	SAVE_JOB_RUN_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorSaveJobRunError("保存任务记录失败"), "dao", err)
+	}
+)
+
+// Job 可以被定时或手动触发的任务
+type Job interface {
+	Name() string
+	Run(ctx context.Context, dryRun bool) (domain.JobResult, error)
+}
+
+type JobService interface {
+	// RegisterJob 注册任务,之后才能通过名称运行
+	RegisterJob(job Job)
+	// RunJob 同步执行任务并记录执行结果
+	RunJob(ctx context.Context, name string, dryRun bool) (*domain.JobRun, error)
+	// TriggerJob 异步执行任务,立即返回执行记录
+	TriggerJob(ctx context.Context, r *domain.TriggerJobRequest) (*domain.TriggerJobResponse, error)
+	// DryRunJob 同步试运行任务,返回将要发出的提醒
+	DryRunJob(ctx context.Context, r *domain.DryRunJobRequest) (*domain.DryRunJobResponse, error)
+	ListJobRuns(ctx context.Context, r *domain.ListJobRunsRequest) (*domain.ListJobRunsResponse, error)
+	// Close 拒绝新的任务并等待执行中的任务结束,超过 ctx 的期限后取消它们
+	Close(ctx context.Context) error
+}
+
+type jobService struct {
+	jobRunDAO dao.JobRunDAO
+	l         logger.Logger
+
+	mu      sync.Mutex
+	jobs    map[string]Job
+	running map[string]bool
+	closed  bool
+
+	// 所有任务共用的上下文,关闭服务超时后取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewJobService(jobRunDAO dao.JobRunDAO, l logger.Logger) JobService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobService{
+		jobRunDAO: jobRunDAO,
+		l:         l,
+		jobs:      make(map[string]Job),
+		running:   make(map[string]bool),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+func (s *jobService) RegisterJob(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.Name()] = job
+}
+
+func (s *jobService) RunJob(ctx context.Context, name string, dryRun bool) (*domain.JobRun, error) {
+	job, run, err := s.start(ctx, name, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	res := s.exec(ctx, job, run)
+	jobRun := toDomainJobRun(run)
+	jobRun.Events = res.Events
+	return jobRun, nil
+}
+
+func (s *jobService) TriggerJob(ctx context.Context, r *domain.TriggerJobRequest) (*domain.TriggerJobResponse, error) {
+	job, run, err := s.start(ctx, r.Name, r.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// 任务可能耗时较长,不能跟随请求的上下文被取消
+	go s.exec(s.ctx, job, run)
+
+	return &domain.TriggerJobResponse{JobRun: toDomainJobRun(run)}, nil
+}
+
+func (s *jobService) DryRunJob(ctx context.Context, r *domain.DryRunJobRequest) (*domain.DryRunJobResponse, error) {
+	run, err := s.RunJob(ctx, r.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.DryRunJobResponse{JobRun: run}, nil
+}
+
+func (s *jobService) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		// 超时后取消仍在执行的任务,并给它们留出保存执行记录的时间
+		s.cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *jobService) ListJobRuns(ctx context.Context, r *domain.ListJobRunsRequest) (*domain.ListJobRunsResponse, error) {
+	limit := r.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	runs, err := s.jobRunDAO.List(ctx, r.Name, r.Offset, limit)
+	if err != nil {
+		return nil, FIND_JOB_RUN_ERROR(err)
+	}
+
+	var res []*domain.JobRun
+	for i := range runs {
+		res = append(res, toDomainJobRun(&runs[i]))
+	}
+	return &domain.ListJobRunsResponse{JobRuns: res}, nil
+}
+
+// start 检查任务能否执行,并写入一条执行中的记录,同一个任务同时只能有一个在执行
+func (s *jobService) start(ctx context.Context, name string, dryRun bool) (Job, *model.JobRun, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	if !ok {
+		s.mu.Unlock()
+		return nil, nil, JOB_NOT_FOUND_ERROR(fmt.Errorf("job %s not registered", name))
+	}
+	if s.closed {
+		s.mu.Unlock()
+		return nil, nil, JOB_CLOSED_ERROR(fmt.Errorf("job service closed"))
+	}
+	if s.running[name] {
+		s.mu.Unlock()
+		return nil, nil, JOB_RUNNING_ERROR(fmt.Errorf("job %s is running", name))
+	}
+	s.running[name] = true
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	run := &model.JobRun{
+		Name:      name,
+		DryRun:    dryRun,
+		Status:    domain.JobStatusRunning,
+		StartedAt: time.Now().Unix(),
+	}
+	err := s.jobRunDAO.Create(ctx, run)
+	if err != nil {
+		s.finish(name)
+		return nil, nil, SAVE_JOB_RUN_ERROR(err)
+	}
+	return job, run, nil
+}
+
+// exec 执行任务并将结果写回执行记录
+func (s *jobService) exec(ctx context.Context, job Job, run *model.JobRun) domain.JobResult {
+	defer s.finish(run.Name)
+
+	// 调用方的上下文和服务关闭时都会取消任务
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	res, err := job.Run(ctx, run.DryRun)
+	run.EndedAt = time.Now().Unix()
+	run.Checked = res.Checked
+	run.Sent = res.Sent
+	run.Failed = res.Failed
+	run.Deleted = res.Deleted
+	run.Purged = res.Purged
+	run.Deferred = res.Deferred
+	run.Sampled = res.Sampled
+	run.Pruned = res.Pruned
+	run.Status = domain.JobStatusSuccess
+	if err != nil {
+		run.Status = domain.JobStatusFailed
+		run.Error = err.Error()
+	}
+
+	// 即使任务的上下文已经结束也要保存执行结果
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := s.jobRunDAO.Save(saveCtx, run); err != nil {
+		s.l.Error("保存任务记录失败", logger.FormatLog("dao", err)...)
+	}
+	return res
+}
+
+func (s *jobService) finish(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, name)
+	s.wg.Done()
+}
+
+func toDomainJobRun(run *model.JobRun) *domain.JobRun {
+	return &domain.JobRun{
+		ID:        run.ID,
+		Name:      run.Name,
+		DryRun:    run.DryRun,
+		Status:    run.Status,
+		StartedAt: run.StartedAt,
+		EndedAt:   run.EndedAt,
+		JobStats: domain.JobStats{
+			Checked:  run.Checked,
+			Sent:     run.Sent,
+			Failed:   run.Failed,
+			Deleted:  run.Deleted,
+			Purged:   run.Purged,
+			Deferred: run.Deferred,
+			Sampled:  run.Sampled,
+			Pruned:   run.Pruned,
+		},
+		Error: run.Error,
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/notifier"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository"
+	"net/mail"
+	"time"
+	// 运行环境可能没有时区数据库
+	_ "time/tzdata"
+)
+
+// DefaultTimezone 学生没有设置时区时使用的时区
+const DefaultTimezone = "Asia/Shanghai"
+
+var (
+	INVALID_PREFERENCE_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidPreferenceError("提醒偏好设置不合法"), "param", err)
+	}
+	FIND_PREFERENCE_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorFindPreferenceError("获取提醒偏好失败"), "dao", err)
+	}
+	SAVE_PREFERENCE_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorSavePreferenceError("保存提醒偏好失败"), "dao", err)
+	}
+)
+
+type NotificationService interface {
+	GetPreference(ctx context.Context, r *domain.GetNotificationPreferenceRequest) (*domain.GetNotificationPreferenceResponse, error)
+	SetPreference(ctx context.Context, r *domain.SetNotificationPreferenceRequest) error
+	// DeliverAt 按学生的免打扰时段和推送时间计算提醒的发送时间,不晚于 now 时应立即发送
+	// 紧急提醒(电费已经欠费)总是立即发送
+	DeliverAt(ctx context.Context, studentId string, critical bool, now time.Time) (time.Time, error)
+	// Defer 保存推迟的提醒,同一学生同一房间同一种类只保留最新的一条
+	Defer(ctx context.Context, alert *domain.DeferredAlert) error
+	FindDueAlerts(ctx context.Context, limit int) ([]*domain.DeferredAlert, error)
+	MarkDelivered(ctx context.Context, id int64, delivered bool) error
+	// Send 通过学生选择的渠道发送提醒,返回实际使用的渠道和渠道的响应
+	Send(ctx context.Context, event *domain.FeedEvent) (channel string, resp string, err error)
+}
+
+type notificationService struct {
+	repo       repository.NotificationRepository
+	dispatcher *notifier.Dispatcher
+	l          logger.Logger
+}
+
+func NewNotificationService(repo repository.NotificationRepository, dispatcher *notifier.Dispatcher, l logger.Logger) NotificationService {
+	return &notificationService{repo: repo, dispatcher: dispatcher, l: l}
+}
+
+func (s *notificationService) GetPreference(ctx context.Context, r *domain.GetNotificationPreferenceRequest) (*domain.GetNotificationPreferenceResponse, error) {
+	pref, err := s.repo.FindPreference(ctx, r.StudentId)
+	if err != nil {
+		return nil, FIND_PREFERENCE_ERROR(err)
+	}
+	if pref.Timezone == "" {
+		pref.Timezone = DefaultTimezone
+	}
+	return &domain.GetNotificationPreferenceResponse{Preference: pref}, nil
+}
+
+func (s *notificationService) SetPreference(ctx context.Context, r *domain.SetNotificationPreferenceRequest) error {
+	pref := r.Preference
+	if pref == nil || pref.StudentId == "" {
+		return INVALID_PREFERENCE_ERROR(fmt.Errorf("学号不能为空"))
+	}
+	if (pref.QuietStart == "") != (pref.QuietEnd == "") {
+		return INVALID_PREFERENCE_ERROR(fmt.Errorf("免打扰开始和结束时间需要同时设置"))
+	}
+	for _, v := range []string{pref.QuietStart, pref.QuietEnd, pref.PreferredTime} {
+		if v == "" {
+			continue
+		}
+		if _, err := parseClock(v); err != nil {
+			return INVALID_PREFERENCE_ERROR(err)
+		}
+	}
+	if pref.Timezone == "" {
+		pref.Timezone = DefaultTimezone
+	}
+	if _, err := time.LoadLocation(pref.Timezone); err != nil {
+		return INVALID_PREFERENCE_ERROR(err)
+	}
+	if err := s.validateChannel(pref.Channel, pref.Address); err != nil {
+		return INVALID_PREFERENCE_ERROR(err)
+	}
+
+	if err := s.repo.SavePreference(ctx, pref); err != nil {
+		return SAVE_PREFERENCE_ERROR(err)
+	}
+	return nil
+}
+
+func (s *notificationService) DeliverAt(ctx context.Context, studentId string, critical bool, now time.Time) (time.Time, error) {
+	if critical {
+		return now, nil
+	}
+	pref, err := s.repo.FindPreference(ctx, studentId)
+	if err != nil {
+		return now, FIND_PREFERENCE_ERROR(err)
+	}
+	return deliverAt(pref, now), nil
+}
+
+func (s *notificationService) Defer(ctx context.Context, alert *domain.DeferredAlert) error {
+	return s.repo.SaveDeferred(ctx, alert)
+}
+
+func (s *notificationService) FindDueAlerts(ctx context.Context, limit int) ([]*domain.DeferredAlert, error) {
+	return s.repo.FindDueDeferred(ctx, time.Now().Unix(), limit)
+}
+
+func (s *notificationService) MarkDelivered(ctx context.Context, id int64, delivered bool) error {
+	return s.repo.MarkDeferred(ctx, id, delivered, time.Now().Unix())
+}
+
+func (s *notificationService) Send(ctx context.Context, event *domain.FeedEvent) (string, string, error) {
+	pref, err := s.repo.FindPreference(ctx, event.StudentId)
+	if err != nil {
+		// 获取不到偏好时使用默认渠道
+		s.l.Warn("获取提醒偏好失败", logger.Error(err), logger.String("studentId", event.StudentId))
+		pref = &domain.NotificationPreference{}
+	}
+	return s.dispatcher.Notify(ctx, pref.Channel, pref.Address, event)
+}
+
+// validateChannel 渠道为空时使用默认渠道,webhook 和 email 渠道需要合法的地址
+func (s *notificationService) validateChannel(channel string, address string) error {
+	if channel == "" {
+		return nil
+	}
+	if !s.dispatcher.Enabled(channel) {
+		return fmt.Errorf("提醒渠道 %s 没有启用", channel)
+	}
+	switch channel {
+	case notifier.ChannelWebhook:
+		// 没有设置地址时使用配置中的转发地址
+		if address == "" {
+			return nil
+		}
+		if err := notifier.ValidateWebhookURL(address); err != nil {
+			return err
+		}
+	case notifier.ChannelEmail:
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("邮箱不合法: %s", address)
+		}
+	}
+	return nil
+}
+
+// deliverAt 设置了推送时间时推迟到下一个推送时间,否则只在免打扰时段内推迟到时段结束
+// 推送时间落在免打扰时段内时同样推迟到时段结束
+func deliverAt(pref *domain.NotificationPreference, now time.Time) time.Time {
+	loc, err := time.LoadLocation(pref.Timezone)
+	if pref.Timezone == "" || err != nil {
+		loc, _ = time.LoadLocation(DefaultTimezone)
+	}
+	local := now.In(loc)
+
+	at := local
+	if preferred, err := parseClock(pref.PreferredTime); err == nil {
+		at = nextClock(local, preferred)
+	}
+
+	start, errStart := parseClock(pref.QuietStart)
+	end, errEnd := parseClock(pref.QuietEnd)
+	if errStart == nil && errEnd == nil && inWindow(at, start, end) {
+		at = nextClock(at, end)
+	}
+	return at
+}
+
+// parseClock 解析 HH:MM,返回距离零点的分钟数
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式应为 HH:MM: %s", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// nextClock 返回 t 之后(含 t)第一个 minute 分钟对应的时刻
+func nextClock(t time.Time, minute int) time.Time {
+	at := time.Date(t.Year(), t.Month(), t.Day(), minute/60, minute%60, 0, 0, t.Location())
+	if at.Before(t) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
+
+// inWindow 判断 t 是否落在 [start, end) 内,start 大于 end 时表示跨过零点
+func inWindow(t time.Time, start, end int) bool {
+	m := t.Hour()*60 + t.Minute()
+	switch {
+	case start == end:
+		return false
+	case start < end:
+		return m >= start && m < end
+	default:
+		return m >= start || m < end
+	}
+}
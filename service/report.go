@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var (
+	FIND_REPORT_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorFindReportError("获取用电报告失败"), "dao", err)
+	}
+	SAVE_REPORT_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorSaveReportError("保存用电统计失败"), "dao", err)
+	}
+)
+
+var (
+	dayValueRegexp = regexp.MustCompile(`<dayValue>(.*?)</dayValue>`)
+	dayMoneyRegexp = regexp.MustCompile(`<dayUseMeony>(.*?)</dayUseMeony>`)
+)
+
+// ReportService 房间的月度用电报告
+type ReportService interface {
+	// GenerateMonthlyReports 统计所有被订阅的房间在 month 的用电,返回需要推送给每位订阅者的报告
+	GenerateMonthlyReports(ctx context.Context, month string) (*domain.MonthlyReportBatch, error)
+	// GetMonthlyReport 获取学生订阅的房间的月度报告,没有统计过的月份会立即统计
+	GetMonthlyReport(ctx context.Context, r *domain.GetMonthlyReportRequest) (*domain.MonthlyReport, error)
+}
+
+type reportService struct {
+	subscriptionRepo repository.SubscriptionRepository
+	groupRepo        repository.RoomGroupRepository
+	usageRepo        repository.MonthlyUsageRepository
+	catalogRepo      repository.CatalogRepository
+	carbon           CarbonEstimator
+	l                logger.Logger
+}
+
+func NewReportService(
+	subscriptionRepo repository.SubscriptionRepository,
+	groupRepo repository.RoomGroupRepository,
+	usageRepo repository.MonthlyUsageRepository,
+	catalogRepo repository.CatalogRepository,
+	carbon CarbonEstimator,
+	l logger.Logger,
+) ReportService {
+	return &reportService{
+		subscriptionRepo: subscriptionRepo,
+		groupRepo:        groupRepo,
+		usageRepo:        usageRepo,
+		catalogRepo:      catalogRepo,
+		carbon:           carbon,
+		l:                l,
+	}
+}
+
+func (s *reportService) GenerateMonthlyReports(ctx context.Context, month string) (*domain.MonthlyReportBatch, error) {
+	from, to, month, err := parsePeriod(month, time.Now())
+	if err != nil {
+		return nil, INVALID_PERIOD_ERROR(err)
+	}
+
+	var (
+		result       = &domain.MonthlyReportBatch{}
+		lastID int64 = -1
+		limit        = 100
+	)
+
+	// 按房间汇总订阅者,个人订阅和群组成员都会收到报告,每个房间只统计一次
+	roomNames := make(map[string]string)
+	recipients := make(map[string][]string)
+	seen := make(map[string]bool)
+	addRecipient := func(roomId string, roomName string, studentId string) {
+		if _, ok := roomNames[roomId]; !ok || roomNames[roomId] == "" {
+			roomNames[roomId] = roomName
+		}
+		if key := roomId + "/" + studentId; !seen[key] {
+			seen[key] = true
+			recipients[roomId] = append(recipients[roomId], studentId)
+		}
+	}
+	for {
+		configs, nextID, err := s.subscriptionRepo.FindByCursor(ctx, lastID, limit)
+		if err != nil {
+			return nil, FIND_CONFIG_ERROR(err)
+		}
+		if len(configs) == 0 {
+			break
+		}
+		for _, cfg := range configs {
+			addRecipient(cfg.RoomId, cfg.RoomName, cfg.StudentId)
+		}
+		lastID = nextID
+	}
+	lastID = -1
+	for {
+		groups, nextID, err := s.groupRepo.FindByCursor(ctx, lastID, limit)
+		if err != nil {
+			return nil, FIND_GROUP_ERROR(err)
+		}
+		if len(groups) == 0 {
+			break
+		}
+		for _, g := range groups {
+			for _, m := range g.Members {
+				addRecipient(g.RoomId, g.RoomName, m)
+			}
+		}
+		lastID = nextID
+	}
+	result.CheckedRooms = int64(len(recipients))
+
+	// 先统计所有房间,之后才能计算楼栋和全校的平均用电
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		semaphore = make(chan struct{}, 10)
+		usages    = make(map[string]*domain.MonthlyUsage, len(recipients))
+		prevs     = make(map[string]*domain.MonthlyUsage, len(recipients))
+	)
+	for roomId := range recipients {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(roomId string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			usage, err := s.collect(ctx, roomId, roomNames[roomId], month, from, to)
+			if err != nil {
+				mu.Lock()
+				result.Errs = append(result.Errs, err)
+				mu.Unlock()
+				return
+			}
+			prev := s.previous(ctx, usage, from)
+			mu.Lock()
+			usages[roomId] = usage
+			prevs[roomId] = prev
+			mu.Unlock()
+		}(roomId)
+	}
+	wg.Wait()
+
+	for roomId, usage := range usages {
+		report, err := s.buildReport(ctx, usage, prevs[roomId])
+		if err != nil {
+			result.Errs = append(result.Errs, err)
+			continue
+		}
+		for _, studentId := range recipients[roomId] {
+			result.MSGs = append(result.MSGs, &domain.MonthlyReportMSG{StudentId: studentId, Report: report})
+		}
+	}
+	return result, nil
+}
+
+func (s *reportService) GetMonthlyReport(ctx context.Context, r *domain.GetMonthlyReportRequest) (*domain.MonthlyReport, error) {
+	// 只能查看自己订阅的房间,房间名称同时用于推断楼栋
+	roomName, err := subscribedRoomName(ctx, s.subscriptionRepo, s.groupRepo, r.StudentId, r.RoomId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	month := r.Month
+	if month == "" {
+		month = LastMonth(now)
+	}
+	from, to, month, err := parsePeriod(month, now)
+	if err != nil {
+		return nil, INVALID_PERIOD_ERROR(err)
+	}
+	if from.After(now) {
+		return nil, INVALID_PERIOD_ERROR(fmt.Errorf("月份 %s 还没有开始", month))
+	}
+
+	usage, err := s.usageRepo.Find(ctx, r.RoomId, month)
+	// 本月的数据还在变化,每次都重新统计
+	if err != nil || !to.Before(now) {
+		if err != nil && !s.usageRepo.IsNotFoundError(err) {
+			return nil, FIND_REPORT_ERROR(err)
+		}
+		usage, err = s.collect(ctx, r.RoomId, roomName, month, from, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.buildReport(ctx, usage, s.previous(ctx, usage, from))
+}
+
+// collect 从学校接口统计房间在 [from, to) 的用电并保存,结束时间最多到昨天
+// 没有房间名称时无法推断楼栋,只返回统计结果而不保存,避免影响楼栋和全校的平均用电
+func (s *reportService) collect(ctx context.Context, roomId string, roomName string, month string, from time.Time, to time.Time) (*domain.MonthlyUsage, error) {
+	usage := &domain.MonthlyUsage{
+		RoomId:   roomId,
+		RoomName: roomName,
+		Building: buildingOf(roomName),
+		Month:    month,
+	}
+
+	end := to.AddDate(0, 0, -1)
+	if yesterday := time.Now().In(from.Location()).AddDate(0, 0, -1); yesterday.Before(end) {
+		end = yesterday
+	}
+	if !end.Before(from) {
+		meterID, err := cachedMeterID(ctx, s.catalogRepo, s.l, roomId)
+		if err != nil {
+			return nil, err
+		}
+		usage.Value, usage.Money, usage.Days, err = fetchDayUsage(ctx, meterID, from, end)
+		if err != nil {
+			return nil, INTERNET_ERROR(err)
+		}
+	}
+
+	if roomName == "" {
+		return usage, nil
+	}
+	if err := s.usageRepo.Save(ctx, usage); err != nil {
+		return nil, SAVE_REPORT_ERROR(err)
+	}
+	return usage, nil
+}
+
+// previous 获取上个月的用电,没有统计过时立即统计,失败或没有数据时返回 nil,报告中不做对比
+func (s *reportService) previous(ctx context.Context, usage *domain.MonthlyUsage, from time.Time) *domain.MonthlyUsage {
+	prevFrom := from.AddDate(0, -1, 0)
+	prevMonth := prevFrom.Format("2006-01")
+	prev, err := s.usageRepo.Find(ctx, usage.RoomId, prevMonth)
+	if s.usageRepo.IsNotFoundError(err) {
+		prev, err = s.collect(ctx, usage.RoomId, usage.RoomName, prevMonth, prevFrom, from)
+	}
+	if err != nil {
+		s.l.Warn("获取上月用电失败", logger.Error(err), logger.String("roomId", usage.RoomId))
+		return nil
+	}
+	if prev.Days == 0 {
+		return nil
+	}
+	return prev
+}
+
+// buildReport 补充楼栋和全校的平均用电,以及它们对应的碳排放
+func (s *reportService) buildReport(ctx context.Context, usage *domain.MonthlyUsage, prev *domain.MonthlyUsage) (*domain.MonthlyReport, error) {
+	report := &domain.MonthlyReport{Current: usage, Previous: prev, Carbon: s.carbon.Estimate(usage.Value)}
+
+	var err error
+	report.Campus, err = s.usageRepo.Average(ctx, usage.Month, "")
+	if err != nil {
+		return nil, FIND_REPORT_ERROR(err)
+	}
+	report.CampusCarbon = s.carbon.Estimate(report.Campus.Value)
+	if usage.Building != "" {
+		report.Building, err = s.usageRepo.Average(ctx, usage.Month, usage.Building)
+		if err != nil {
+			return nil, FIND_REPORT_ERROR(err)
+		}
+		report.BuildingCarbon = s.carbon.Estimate(report.Building.Value)
+	}
+	return report, nil
+}
+
+// fetchDayUsage 汇总电表在 [from, end] 每天的用电量和电费,返回有数据的天数
+func fetchDayUsage(ctx context.Context, meterID string, from time.Time, end time.Time) (float64, float64, int64, error) {
+	body, err := sendRequest(ctx, fmt.Sprintf("https://jnb.ccnu.edu.cn/ICBS/PurchaseWebService.asmx/getMeterDayValue?AmMeter_ID=%s&startDate=%s&endDate=%s",
+		meterID, url.QueryEscape(from.Format("2006/1/2")), url.QueryEscape(end.Format("2006/1/2"))))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var (
+		value, money float64
+		days         int64
+	)
+	for _, m := range dayValueRegexp.FindAllStringSubmatch(body, -1) {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		value += v
+		days++
+	}
+	for _, m := range dayMoneyRegexp.FindAllStringSubmatch(body, -1) {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		money += v
+	}
+	return roundMoney(value), roundMoney(money), days, nil
+}
+
+// buildingOf 从房间名称推断楼栋,如 东区1栋101 为 东区1栋,东16-101空调 为 东16
+func buildingOf(roomName string) string {
+	if i := strings.LastIndex(roomName, "栋"); i > 0 {
+		return roomName[:i+len("栋")]
+	}
+	// 去掉 空调、照明 等后缀,再去掉末尾的房间号
+	room := strings.TrimRightFunc(roomName, func(r rune) bool { return !unicode.IsDigit(r) })
+	building := strings.TrimRight(strings.TrimRightFunc(room, unicode.IsDigit), "-")
+	if building == "" || building == room {
+		return ""
+	}
+	return building
+}
+
+// LastMonth 返回 now 在默认时区的上一个月,格式为 YYYY-MM
+func LastMonth(now time.Time) string {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		loc = time.Local
+	}
+	n := now.In(loc)
+	return time.Date(n.Year(), n.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, -1, 0).Format("2006-01")
+}
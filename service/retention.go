@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository"
+	"strconv"
+	"time"
+)
+
+// EnrollmentYearRule 从学号推断入学年份,无法推断时返回 false
+type EnrollmentYearRule interface {
+	EnrollmentYear(studentId string) (int, bool)
+}
+
+// PrefixYearRule 学号前四位即入学年份,例如 2022214xxx
+type PrefixYearRule struct{}
+
+func NewPrefixYearRule() EnrollmentYearRule {
+	return PrefixYearRule{}
+}
+
+func (PrefixYearRule) EnrollmentYear(studentId string) (int, bool) {
+	if len(studentId) < 4 {
+		return 0, false
+	}
+	year, err := strconv.Atoi(studentId[:4])
+	if err != nil || year < 1900 {
+		return 0, false
+	}
+	return year, true
+}
+
+type RetentionService interface {
+	// CleanGraduated 软删除毕业超过保留年限的学生的订阅,并彻底删除软删除超过宽限期的订阅和过期的电费读数
+	CleanGraduated(ctx context.Context, r *domain.CleanGraduatedRequest) (*domain.CleanGraduatedResponse, error)
+}
+
+type retentionService struct {
+	subscriptionRepo repository.SubscriptionRepository
+	readingRepo      repository.ReadingRepository
+	rule             EnrollmentYearRule
+	l                logger.Logger
+}
+
+func NewRetentionService(
+	subscriptionRepo repository.SubscriptionRepository,
+	readingRepo repository.ReadingRepository,
+	rule EnrollmentYearRule,
+	l logger.Logger,
+) RetentionService {
+	return &retentionService{subscriptionRepo: subscriptionRepo, readingRepo: readingRepo, rule: rule, l: l}
+}
+
+func (s *retentionService) CleanGraduated(ctx context.Context, r *domain.CleanGraduatedRequest) (*domain.CleanGraduatedResponse, error) {
+	var (
+		res          = &domain.CleanGraduatedResponse{}
+		now          = time.Now()
+		lastID int64 = -1
+		limit        = 100
+	)
+
+	for {
+		configs, nextID, err := s.subscriptionRepo.FindByCursor(ctx, lastID, limit)
+		if err != nil {
+			return nil, FIND_CONFIG_ERROR(err)
+		}
+		if len(configs) == 0 {
+			break
+		}
+
+		var stale []*domain.Subscription
+		for _, cfg := range configs {
+			res.Checked++
+			if s.isStale(cfg.StudentId, r, now) {
+				stale = append(stale, cfg)
+			}
+		}
+
+		if r.DryRun {
+			res.Deleted += int64(len(stale))
+		} else {
+			deleted, err := s.subscriptionRepo.SoftDelete(ctx, stale)
+			if err != nil {
+				return nil, SAVE_CONFIG_ERROR(err)
+			}
+			res.Deleted += deleted
+		}
+
+		lastID = nextID
+	}
+
+	// 软删除超过宽限期的数据彻底删除
+	before := now.Add(-r.PurgeAfter)
+	if r.DryRun {
+		purged, err := s.subscriptionRepo.CountDeletedBefore(ctx, before)
+		if err != nil {
+			return nil, FIND_CONFIG_ERROR(err)
+		}
+		res.Purged = purged
+	} else {
+		purged, err := s.subscriptionRepo.PurgeDeletedBefore(ctx, before)
+		if err != nil {
+			return nil, SAVE_CONFIG_ERROR(err)
+		}
+		res.Purged = purged
+	}
+
+	pruned, err := s.pruneReadings(ctx, r, now)
+	if err != nil {
+		return nil, err
+	}
+	res.Pruned = pruned
+	return res, nil
+}
+
+// pruneReadings 分批彻底删除超过保留时长的电费读数,后台采样会让读数持续增长
+func (s *retentionService) pruneReadings(ctx context.Context, r *domain.CleanGraduatedRequest, now time.Time) (int64, error) {
+	if r.ReadingRetain <= 0 {
+		return 0, nil
+	}
+	before := now.Add(-r.ReadingRetain).Unix()
+	if r.DryRun {
+		count, err := s.readingRepo.CountBefore(ctx, before)
+		if err != nil {
+			return 0, FIND_CONFIG_ERROR(err)
+		}
+		return count, nil
+	}
+
+	const batchSize = 1000
+	var pruned int64
+	for {
+		deleted, err := s.readingRepo.DeleteBefore(ctx, before, batchSize)
+		if err != nil {
+			return pruned, SAVE_CONFIG_ERROR(err)
+		}
+		pruned += deleted
+		if deleted < batchSize {
+			return pruned, nil
+		}
+	}
+}
+
+// isStale 按入学年份加学制推算毕业时间(7月1日),超过保留年限即为过期
+func (s *retentionService) isStale(studentId string, r *domain.CleanGraduatedRequest, now time.Time) bool {
+	year, ok := s.rule.EnrollmentYear(studentId)
+	if !ok {
+		return false
+	}
+	graduation := time.Date(year+r.StudyYears, time.July, 1, 0, 0, 0, 0, now.Location())
+	return now.After(graduation.AddDate(r.RetainYears, 0, 0))
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository"
+	"math/big"
+)
+
+const (
+	// maxGroupMembers 一个房间群组最多的成员数
+	maxGroupMembers = 8
+	// inviteCodeAlphabet 去掉了容易混淆的 0/O/1/I
+	inviteCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	inviteCodeLength   = 8
+)
+
+var (
+	GROUP_NOT_FOUND_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorGroupNotFoundError("群组不存在"), "param", err)
+	}
+	GROUP_PERMISSION_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorGroupPermissionError("没有权限操作该群组"), "param", err)
+	}
+	GROUP_FULL_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorGroupFullError("群组人数已满"), "param", err)
+	}
+	INVALID_GROUP_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidGroupError("群组设置不合法"), "param", err)
+	}
+	FIND_GROUP_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorFindGroupError("获取群组失败"), "dao", err)
+	}
+	SAVE_GROUP_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorSaveGroupError("保存群组失败"), "dao", err)
+	}
+)
+
+// RoomGroupService 室友共享房间订阅,创建者通过邀请码邀请室友加入
+type RoomGroupService interface {
+	CreateGroup(ctx context.Context, r *domain.CreateRoomGroupRequest) (*domain.RoomGroup, error)
+	JoinGroup(ctx context.Context, r *domain.JoinRoomGroupRequest) (*domain.RoomGroup, error)
+	// LeaveGroup 退出群组,创建者退出时转交给最早加入的成员,最后一位成员退出时删除群组
+	LeaveGroup(ctx context.Context, r *domain.LeaveRoomGroupRequest) error
+	// UpdateGroup 修改群组的阈值和提醒方式,只有创建者可以修改
+	UpdateGroup(ctx context.Context, r *domain.UpdateRoomGroupRequest) error
+	DeleteGroup(ctx context.Context, r *domain.DeleteRoomGroupRequest) error
+	ListGroups(ctx context.Context, r *domain.ListRoomGroupsRequest) (*domain.ListRoomGroupsResponse, error)
+}
+
+type roomGroupService struct {
+	repo repository.RoomGroupRepository
+	l    logger.Logger
+}
+
+func NewRoomGroupService(repo repository.RoomGroupRepository, l logger.Logger) RoomGroupService {
+	return &roomGroupService{repo: repo, l: l}
+}
+
+func (s *roomGroupService) CreateGroup(ctx context.Context, r *domain.CreateRoomGroupRequest) (*domain.RoomGroup, error) {
+	if r.StudentId == "" || r.RoomId == "" {
+		return nil, INVALID_GROUP_ERROR(errors.New("学号和房间不能为空"))
+	}
+	mode, err := validateGroup(r.DeliveryMode, r.Thresholds)
+	if err != nil {
+		return nil, err
+	}
+	code, err := s.newInviteCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &domain.RoomGroup{
+		RoomId:       r.RoomId,
+		RoomName:     r.RoomName,
+		OwnerId:      r.StudentId,
+		InviteCode:   code,
+		DeliveryMode: mode,
+		Thresholds:   r.Thresholds,
+	}
+	if err := s.repo.Create(ctx, g); err != nil {
+		return nil, SAVE_GROUP_ERROR(err)
+	}
+	return g, nil
+}
+
+func (s *roomGroupService) JoinGroup(ctx context.Context, r *domain.JoinRoomGroupRequest) (*domain.RoomGroup, error) {
+	g, err := s.repo.FindByInviteCode(ctx, r.InviteCode)
+	if s.repo.IsNotFoundError(err) {
+		return nil, GROUP_NOT_FOUND_ERROR(fmt.Errorf("邀请码 %s 不存在", r.InviteCode))
+	}
+	if err != nil {
+		return nil, FIND_GROUP_ERROR(err)
+	}
+	if isMember(g, r.StudentId) {
+		return g, nil
+	}
+
+	// 人数上限在加入时检查,避免并发加入超过上限
+	added, err := s.repo.AddMember(ctx, g.ID, r.StudentId, maxGroupMembers)
+	if s.repo.IsNotFoundError(err) {
+		return nil, GROUP_NOT_FOUND_ERROR(fmt.Errorf("群组 %d 已解散", g.ID))
+	}
+	if err != nil {
+		return nil, SAVE_GROUP_ERROR(err)
+	}
+	if !added {
+		return nil, GROUP_FULL_ERROR(fmt.Errorf("群组 %d 已有 %d 人", g.ID, maxGroupMembers))
+	}
+	g.Members = append(g.Members, r.StudentId)
+	return g, nil
+}
+
+func (s *roomGroupService) LeaveGroup(ctx context.Context, r *domain.LeaveRoomGroupRequest) error {
+	g, err := s.findMemberGroup(ctx, r.GroupId, r.StudentId)
+	if err != nil {
+		return err
+	}
+
+	var rest []string
+	for _, m := range g.Members {
+		if m != r.StudentId {
+			rest = append(rest, m)
+		}
+	}
+	if len(rest) == 0 {
+		if err := s.repo.Delete(ctx, g.ID); err != nil {
+			return SAVE_GROUP_ERROR(err)
+		}
+		return nil
+	}
+
+	if g.OwnerId == r.StudentId {
+		if err := s.repo.UpdateOwner(ctx, g.ID, rest[0]); err != nil {
+			return SAVE_GROUP_ERROR(err)
+		}
+	}
+	if err := s.repo.RemoveMember(ctx, g.ID, r.StudentId); err != nil {
+		return SAVE_GROUP_ERROR(err)
+	}
+	return nil
+}
+
+func (s *roomGroupService) UpdateGroup(ctx context.Context, r *domain.UpdateRoomGroupRequest) error {
+	g, err := s.findOwnedGroup(ctx, r.GroupId, r.StudentId)
+	if err != nil {
+		return err
+	}
+	mode, err := validateGroup(r.DeliveryMode, r.Thresholds)
+	if err != nil {
+		return err
+	}
+
+	g.DeliveryMode = mode
+	g.Thresholds = r.Thresholds
+	if err := s.repo.Update(ctx, g); err != nil {
+		return SAVE_GROUP_ERROR(err)
+	}
+	return nil
+}
+
+func (s *roomGroupService) DeleteGroup(ctx context.Context, r *domain.DeleteRoomGroupRequest) error {
+	g, err := s.findOwnedGroup(ctx, r.GroupId, r.StudentId)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, g.ID); err != nil {
+		return SAVE_GROUP_ERROR(err)
+	}
+	return nil
+}
+
+func (s *roomGroupService) ListGroups(ctx context.Context, r *domain.ListRoomGroupsRequest) (*domain.ListRoomGroupsResponse, error) {
+	gs, err := s.repo.FindByStudent(ctx, r.StudentId)
+	if err != nil {
+		return nil, FIND_GROUP_ERROR(err)
+	}
+	return &domain.ListRoomGroupsResponse{Groups: gs}, nil
+}
+
+// findMemberGroup 获取学生所在的群组,不是成员时当作群组不存在
+func (s *roomGroupService) findMemberGroup(ctx context.Context, groupId int64, studentId string) (*domain.RoomGroup, error) {
+	g, err := s.repo.FindByID(ctx, groupId)
+	if s.repo.IsNotFoundError(err) {
+		return nil, GROUP_NOT_FOUND_ERROR(fmt.Errorf("群组 %d 不存在", groupId))
+	}
+	if err != nil {
+		return nil, FIND_GROUP_ERROR(err)
+	}
+	if !isMember(g, studentId) {
+		return nil, GROUP_NOT_FOUND_ERROR(fmt.Errorf("学生 %s 不在群组 %d 中", studentId, groupId))
+	}
+	return g, nil
+}
+
+func (s *roomGroupService) findOwnedGroup(ctx context.Context, groupId int64, studentId string) (*domain.RoomGroup, error) {
+	g, err := s.findMemberGroup(ctx, groupId, studentId)
+	if err != nil {
+		return nil, err
+	}
+	if g.OwnerId != studentId {
+		return nil, GROUP_PERMISSION_ERROR(fmt.Errorf("学生 %s 不是群组 %d 的创建者", studentId, groupId))
+	}
+	return g, nil
+}
+
+// newInviteCode 生成未被使用的邀请码
+func (s *roomGroupService) newInviteCode(ctx context.Context) (string, error) {
+	for i := 0; i < 5; i++ {
+		code, err := randomInviteCode()
+		if err != nil {
+			return "", SAVE_GROUP_ERROR(err)
+		}
+		_, err = s.repo.FindByInviteCode(ctx, code)
+		if s.repo.IsNotFoundError(err) {
+			return code, nil
+		}
+		if err != nil {
+			return "", FIND_GROUP_ERROR(err)
+		}
+	}
+	return "", SAVE_GROUP_ERROR(errors.New("生成邀请码失败"))
+}
+
+func randomInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// validateGroup 群组至少需要一个阈值,提醒方式为空时默认提醒所有成员
+func validateGroup(mode string, thresholds []*domain.Threshold) (string, error) {
+	switch mode {
+	case "":
+		mode = domain.DeliveryModeAll
+	case domain.DeliveryModeAll, domain.DeliveryModeRotate:
+	default:
+		return "", INVALID_GROUP_ERROR(fmt.Errorf("提醒方式不合法: %s", mode))
+	}
+	if len(thresholds) == 0 {
+		return "", INVALID_GROUP_ERROR(errors.New("至少需要设置一个阈值"))
+	}
+	if err := validateThresholds(thresholds); err != nil {
+		return "", INVALID_GROUP_ERROR(err)
+	}
+	return mode, nil
+}
+
+// subscribedRoomName 学生通过个人订阅或房间群组订阅了该房间时返回房间名称,房间相关的接口都以此鉴权
+func subscribedRoomName(
+	ctx context.Context,
+	subscriptionRepo repository.SubscriptionRepository,
+	groupRepo repository.RoomGroupRepository,
+	studentId string,
+	roomId string,
+) (string, error) {
+	subs, err := subscriptionRepo.FindByStudent(ctx, studentId)
+	if err != nil {
+		return "", FIND_CONFIG_ERROR(err)
+	}
+	for _, sub := range subs {
+		if sub.RoomId == roomId {
+			return sub.RoomName, nil
+		}
+	}
+	groups, err := groupRepo.FindByStudent(ctx, studentId)
+	if err != nil {
+		return "", FIND_GROUP_ERROR(err)
+	}
+	for _, g := range groups {
+		if g.RoomId == roomId {
+			return g.RoomName, nil
+		}
+	}
+	return "", STANDARD_NOT_FOUND_ERROR(fmt.Errorf("学生 %s 没有订阅房间 %s", studentId, roomId))
+}
+
+func isMember(g *domain.RoomGroup, studentId string) bool {
+	for _, m := range g.Members {
+		if m == studentId {
+			return true
+		}
+	}
+	return false
+}
+
+// groupRecipients 返回群组这次需要提醒的成员和提醒之后下一次轮流提醒的成员序号
+// 轮流提醒时从当前序号开始跳过不需要提醒的成员,下一次从实际提醒的成员之后开始
+func groupRecipients(g *domain.RoomGroup, skip func(studentId string) bool) ([]string, int64) {
+	if len(g.Members) == 0 {
+		return nil, g.RotateCursor
+	}
+	if g.DeliveryMode != domain.DeliveryModeRotate {
+		var res []string
+		for _, m := range g.Members {
+			if !skip(m) {
+				res = append(res, m)
+			}
+		}
+		return res, g.RotateCursor
+	}
+
+	n := int64(len(g.Members))
+	for i := int64(0); i < n; i++ {
+		idx := (g.RotateCursor + i) % n
+		if !skip(g.Members[idx]) {
+			return []string{g.Members[idx]}, idx + 1
+		}
+	}
+	return nil, g.RotateCursor
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"github.com/spf13/viper"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SamplerConfig 后台采样的参数,采样任务和提醒检查共用
+type SamplerConfig struct {
+	Enabled         bool  `yaml:"enabled"`
+	IntervalMinutes int64 `yaml:"intervalMinutes"` // 每个房间的采样间隔,单位分钟
+	DryRun          bool  `yaml:"dryRun"`          // 试运行,只统计要采样的房间而不请求学校接口
+}
+
+func LoadSamplerConfig() SamplerConfig {
+	cfg := SamplerConfig{IntervalMinutes: 60}
+	if err := viper.UnmarshalKey("sampler", &cfg); err != nil {
+		panic(err)
+	}
+	if cfg.IntervalMinutes <= 0 {
+		cfg.IntervalMinutes = 60
+	}
+	return cfg
+}
+
+func (s *elecpriceService) SubscribedRooms(ctx context.Context) ([]string, error) {
+	var (
+		lastID int64 = -1
+		limit        = 100
+	)
+	rooms := make(map[string]struct{})
+	for {
+		configs, nextID, err := s.subscriptionRepo.FindByCursor(ctx, lastID, limit)
+		if err != nil {
+			return nil, FIND_CONFIG_ERROR(err)
+		}
+		if len(configs) == 0 {
+			break
+		}
+		for _, cfg := range configs {
+			rooms[cfg.RoomId] = struct{}{}
+		}
+		lastID = nextID
+	}
+	lastID = -1
+	for {
+		groups, nextID, err := s.groupRepo.FindByCursor(ctx, lastID, limit)
+		if err != nil {
+			return nil, FIND_GROUP_ERROR(err)
+		}
+		if len(groups) == 0 {
+			break
+		}
+		for _, g := range groups {
+			rooms[g.RoomId] = struct{}{}
+		}
+		lastID = nextID
+	}
+
+	res := make([]string, 0, len(rooms))
+	for roomId := range rooms {
+		res = append(res, roomId)
+	}
+	// 固定顺序,每个房间在每轮采样中的时间大致相同
+	sort.Strings(res)
+	return res, nil
+}
+
+// currentRemain 获取房间的余额,开启后台采样且最近的读数足够新时直接使用读数,否则实时查询
+// 允许错过一次采样,超过两个采样间隔的读数视为过期
+func (s *elecpriceService) currentRemain(ctx context.Context, roomId string, now time.Time) (string, error) {
+	if s.samplerCfg.Enabled && !s.samplerCfg.DryRun {
+		maxAge := 2 * time.Duration(s.samplerCfg.IntervalMinutes) * time.Minute
+		if reading, err := s.readingRepo.FindLatest(ctx, roomId); err == nil && reading.ReadAt >= now.Add(-maxAge).Unix() {
+			return strconv.FormatFloat(reading.RemainMoney, 'f', 2, 64), nil
+		}
+	}
+	price, err := s.GetPrice(ctx, roomId)
+	if err != nil {
+		return "", err
+	}
+	return price.RemainMoney, nil
+}
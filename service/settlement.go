@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/repository"
+	"math"
+	"time"
+)
+
+// minRechargeAmount 余额上升至少这么多才认为是充值,避免读数误差
+const minRechargeAmount = 0.01
+
+var (
+	INVALID_PERIOD_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidPeriodError("结算周期不合法"), "param", err)
+	}
+	RECHARGE_NOT_FOUND_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorRechargeNotFoundError("充值记录不存在"), "param", err)
+	}
+	RECHARGE_CLAIMED_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorRechargeClaimedError("充值已被其他成员认领"), "param", err)
+	}
+	FIND_SETTLEMENT_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorFindSettlementError("获取结算数据失败"), "dao", err)
+	}
+)
+
+// SettlementService 房间群组的电费分摊,用电金额由成员平摊,充值由认领的成员支付
+type SettlementService interface {
+	GetSettlement(ctx context.Context, r *domain.GetSettlementRequest) (*domain.Settlement, error)
+	// ClaimRecharge 群组成员认领一次充值,每次充值只能被认领一次,已被其他成员认领时返回 RECHARGE_CLAIMED_ERROR
+	// 同一成员重复认领视为成功
+	ClaimRecharge(ctx context.Context, r *domain.ClaimRechargeRequest) error
+}
+
+type settlementService struct {
+	groupRepo    repository.RoomGroupRepository
+	readingRepo  repository.ReadingRepository
+	rechargeRepo repository.RechargeRepository
+}
+
+func NewSettlementService(
+	groupRepo repository.RoomGroupRepository,
+	readingRepo repository.ReadingRepository,
+	rechargeRepo repository.RechargeRepository,
+) SettlementService {
+	return &settlementService{groupRepo: groupRepo, readingRepo: readingRepo, rechargeRepo: rechargeRepo}
+}
+
+func (s *settlementService) GetSettlement(ctx context.Context, r *domain.GetSettlementRequest) (*domain.Settlement, error) {
+	g, err := s.findMemberGroup(ctx, r.GroupId, r.StudentId)
+	if err != nil {
+		return nil, err
+	}
+	from, to, period, err := parsePeriod(r.Period, time.Now())
+	if err != nil {
+		return nil, INVALID_PERIOD_ERROR(err)
+	}
+
+	readings, err := s.readingRepo.FindRange(ctx, g.RoomId, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, FIND_SETTLEMENT_ERROR(err)
+	}
+	recharges, err := s.rechargeRepo.FindRange(ctx, g.RoomId, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, FIND_SETTLEMENT_ERROR(err)
+	}
+
+	res := &domain.Settlement{
+		GroupId:   g.ID,
+		RoomId:    g.RoomId,
+		Period:    period,
+		From:      from.Unix(),
+		To:        to.Unix(),
+		Recharges: recharges,
+	}
+
+	paid := make(map[string]float64, len(g.Members))
+	for _, rc := range recharges {
+		res.Recharged += rc.Amount
+		if isMember(g, rc.PaidBy) {
+			paid[rc.PaidBy] += rc.Amount
+		} else {
+			// 未认领或者认领人已经退出群组的充值不计入账目
+			res.Unclaimed += rc.Amount
+		}
+	}
+
+	// 用电金额 = 期初余额 + 期间充值 - 期末余额,期初的充值已经包含在第一次读数中
+	if len(readings) > 0 {
+		first, last := readings[0], readings[len(readings)-1]
+		var rechargedAfterFirst float64
+		for _, rc := range recharges {
+			if rc.DetectedAt > first.ReadAt {
+				rechargedAfterFirst += rc.Amount
+			}
+		}
+		res.Consumed = math.Max(0, first.RemainMoney+rechargedAfterFirst-last.RemainMoney)
+	}
+
+	share := 0.0
+	if len(g.Members) > 0 {
+		share = res.Consumed / float64(len(g.Members))
+	}
+	for _, m := range g.Members {
+		res.Members = append(res.Members, &domain.MemberSettlement{
+			StudentId: m,
+			Paid:      roundMoney(paid[m]),
+			Share:     roundMoney(share),
+			Balance:   roundMoney(paid[m] - share),
+		})
+	}
+	res.Consumed = roundMoney(res.Consumed)
+	res.Recharged = roundMoney(res.Recharged)
+	res.Unclaimed = roundMoney(res.Unclaimed)
+	return res, nil
+}
+
+func (s *settlementService) ClaimRecharge(ctx context.Context, r *domain.ClaimRechargeRequest) error {
+	g, err := s.findMemberGroup(ctx, r.GroupId, r.StudentId)
+	if err != nil {
+		return err
+	}
+	rc, err := s.rechargeRepo.FindByID(ctx, r.RechargeId)
+	if s.rechargeRepo.IsNotFoundError(err) || (err == nil && rc.RoomId != g.RoomId) {
+		return RECHARGE_NOT_FOUND_ERROR(fmt.Errorf("房间 %s 没有充值记录 %d", g.RoomId, r.RechargeId))
+	}
+	if err != nil {
+		return FIND_SETTLEMENT_ERROR(err)
+	}
+
+	if rc.PaidBy == r.StudentId {
+		return nil
+	}
+	// 只能认领还没有被认领的充值,条件更新避免两位成员同时认领时互相覆盖
+	claimed, err := s.rechargeRepo.Claim(ctx, rc.ID, r.StudentId)
+	if err != nil {
+		return SAVE_GROUP_ERROR(err)
+	}
+	if !claimed {
+		return RECHARGE_CLAIMED_ERROR(fmt.Errorf("充值 %d 已被认领", rc.ID))
+	}
+	return nil
+}
+
+func (s *settlementService) findMemberGroup(ctx context.Context, groupId int64, studentId string) (*domain.RoomGroup, error) {
+	g, err := s.groupRepo.FindByID(ctx, groupId)
+	if s.groupRepo.IsNotFoundError(err) {
+		return nil, GROUP_NOT_FOUND_ERROR(fmt.Errorf("群组 %d 不存在", groupId))
+	}
+	if err != nil {
+		return nil, FIND_GROUP_ERROR(err)
+	}
+	if !isMember(g, studentId) {
+		return nil, GROUP_NOT_FOUND_ERROR(fmt.Errorf("学生 %s 不在群组 %d 中", studentId, groupId))
+	}
+	return g, nil
+}
+
+// parsePeriod 解析 YYYY-MM 格式的月份,返回该月在默认时区的 [from, to),为空时为 now 所在的月份
+func parsePeriod(period string, now time.Time) (time.Time, time.Time, string, error) {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+	var from time.Time
+	if period == "" {
+		n := now.In(loc)
+		from = time.Date(n.Year(), n.Month(), 1, 0, 0, 0, 0, loc)
+	} else {
+		from, err = time.ParseInLocation("2006-01", period, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, "", fmt.Errorf("周期格式应为 YYYY-MM: %s", period)
+		}
+	}
+	return from, from.AddDate(0, 1, 0), from.Format("2006-01"), nil
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
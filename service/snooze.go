@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"strconv"
+	"time"
+)
+
+func (s *elecpriceService) AcknowledgeAlert(ctx context.Context, r *domain.AcknowledgeAlertRequest) error {
+	return s.snooze(ctx, r.StudentId, r.RoomId, 0)
+}
+
+func (s *elecpriceService) SnoozeStandard(ctx context.Context, r *domain.SnoozeStandardRequest) error {
+	if r.Until <= time.Now().Unix() {
+		return INVALID_SNOOZE_ERROR(fmt.Errorf("暂停截止时间 %d 早于当前时间", r.Until))
+	}
+	return s.snooze(ctx, r.StudentId, r.RoomId, r.Until)
+}
+
+// snooze 记录暂停时的余额,之后的检查以此判断电费是否继续下降
+func (s *elecpriceService) snooze(ctx context.Context, studentId string, roomId string, until int64) error {
+	// 通过房间群组订阅的成员同样可以暂停
+	if _, err := subscribedRoomName(ctx, s.subscriptionRepo, s.groupRepo, studentId, roomId); err != nil {
+		return err
+	}
+
+	remain, err := s.latestRemain(ctx, roomId)
+	if err != nil {
+		return err
+	}
+
+	err = s.snoozeRepo.Save(ctx, &domain.Snooze{
+		StudentId: studentId,
+		RoomId:    roomId,
+		Until:     until,
+		Remain:    remain,
+	})
+	if err != nil {
+		return SAVE_CONFIG_ERROR(err)
+	}
+	return nil
+}
+
+// latestRemain 优先使用最近一次检查的读数,没有读数时实时获取
+func (s *elecpriceService) latestRemain(ctx context.Context, roomId string) (float64, error) {
+	if reading, err := s.readingRepo.FindLatest(ctx, roomId); err == nil {
+		return reading.RemainMoney, nil
+	}
+
+	price, err := s.GetPrice(ctx, roomId)
+	if err != nil {
+		return 0, err
+	}
+	remain, err := strconv.ParseFloat(price.RemainMoney, 64)
+	if err != nil {
+		return 0, INTERNET_ERROR(fmt.Errorf("解析电费数据失败: %w", err))
+	}
+	return remain, nil
+}
+
+// activeSnoozes 返回房间中仍在暂停提醒的学生
+// 暂停到期、电费继续下降超过设定幅度或者已经充值的暂停记录会被删除
+func (s *elecpriceService) activeSnoozes(ctx context.Context, roomId string, remain float64) (map[string]bool, error) {
+	snoozes, err := s.snoozeRepo.FindByRoom(ctx, roomId)
+	if err != nil {
+		return nil, FIND_CONFIG_ERROR(err)
+	}
+
+	var (
+		now     = time.Now().Unix()
+		active  = make(map[string]bool, len(snoozes))
+		expired []int64
+	)
+	for _, sn := range snoozes {
+		switch {
+		case sn.Until != 0 && now >= sn.Until:
+		case remain <= sn.Remain-s.snoozeCfg.Margin:
+		case remain > sn.Remain:
+		default:
+			active[sn.StudentId] = true
+			continue
+		}
+		expired = append(expired, sn.ID)
+	}
+
+	if err := s.snoozeRepo.Delete(ctx, expired); err != nil {
+		// 删除失败不影响本次判断,下次检查会再次删除
+		s.l.Warn("删除过期的提醒暂停失败", logger.Error(err), logger.String("roomId", roomId))
+	}
+	return active, nil
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository"
+	"github.com/asynccnu/be-elecprice/service/usage"
+	"github.com/spf13/viper"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ARCHITECTURE_NOT_FOUND_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorArchitectureNotFoundError("楼栋不存在"), "param", err)
+	}
+	ROOM_NOT_IN_ARCHITECTURE_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorRoomNotInArchitectureError("房间不属于该楼栋"), "param", err)
+	}
+	FIND_STATS_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorFindStatsError("获取用电统计失败"), "dao", err)
+	}
+	SAVE_LEADERBOARD_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorSaveLeaderboardError("保存排行榜报名失败"), "dao", err)
+	}
+)
+
+// balanceBounds 余额分布的分段,最后一段没有上限
+var balanceBounds = []float64{0, 10, 30, 50, 100}
+
+type StatsConfig struct {
+	Days     int     `yaml:"days"`     // 统计最近多少天的读数
+	NearZero float64 `yaml:"nearZero"` // 余额低于该金额(元)视为接近耗尽
+}
+
+// StatsService 楼栋和区域的用电统计,以及同楼栋房间自愿参加的节能排行榜
+type StatsService interface {
+	GetBuildingStats(ctx context.Context, r *domain.GetBuildingStatsRequest) (*domain.BuildingStats, error)
+	// JoinLeaderboard 订阅了房间的学生为房间报名排行榜,房间必须属于指定的楼栋
+	JoinLeaderboard(ctx context.Context, r *domain.JoinLeaderboardRequest) error
+	LeaveLeaderboard(ctx context.Context, r *domain.LeaveLeaderboardRequest) error
+	// GetLeaderboard 按日均用电从低到高排列楼栋中报名的房间,没有读数的房间不参与排名
+	GetLeaderboard(ctx context.Context, r *domain.GetLeaderboardRequest) ([]*domain.LeaderboardEntry, error)
+}
+
+type statsService struct {
+	elecpriceSer     ElecpriceService
+	subscriptionRepo repository.SubscriptionRepository
+	groupRepo        repository.RoomGroupRepository
+	readingRepo      repository.ReadingRepository
+	leaderboardRepo  repository.LeaderboardRepository
+	cfg              StatsConfig
+	l                logger.Logger
+}
+
+func NewStatsService(
+	elecpriceSer ElecpriceService,
+	subscriptionRepo repository.SubscriptionRepository,
+	groupRepo repository.RoomGroupRepository,
+	readingRepo repository.ReadingRepository,
+	leaderboardRepo repository.LeaderboardRepository,
+	l logger.Logger,
+) StatsService {
+	cfg := StatsConfig{Days: 7, NearZero: 5}
+	if err := viper.UnmarshalKey("stats", &cfg); err != nil {
+		panic(err)
+	}
+	return &statsService{
+		elecpriceSer:     elecpriceSer,
+		subscriptionRepo: subscriptionRepo,
+		groupRepo:        groupRepo,
+		readingRepo:      readingRepo,
+		leaderboardRepo:  leaderboardRepo,
+		cfg:              cfg,
+		l:                l,
+	}
+}
+
+func (s *statsService) GetBuildingStats(ctx context.Context, r *domain.GetBuildingStatsRequest) (*domain.BuildingStats, error) {
+	archis, err := s.architectures(ctx, r.Area, r.ArchitectureId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 各楼栋的房间分别统计,区域的统计使用所有房间而不是楼栋统计的平均
+	var (
+		allRooms []string
+		children []*domain.BuildingStats
+	)
+	for _, a := range archis {
+		rooms, err := s.architectureRooms(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(rooms))
+		for id := range rooms {
+			ids = append(ids, id)
+		}
+		stats, err := s.aggregate(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		stats.Area = r.Area
+		stats.ArchitectureId = a.ArchitectureID
+		stats.ArchitectureName = a.ArchitectureName
+		children = append(children, stats)
+		allRooms = append(allRooms, ids...)
+	}
+
+	if r.ArchitectureId != "" {
+		return children[0], nil
+	}
+	res, err := s.aggregate(ctx, allRooms)
+	if err != nil {
+		return nil, err
+	}
+	res.Area = r.Area
+	res.Architectures = children
+	return res, nil
+}
+
+func (s *statsService) JoinLeaderboard(ctx context.Context, r *domain.JoinLeaderboardRequest) error {
+	roomName, err := subscribedRoomName(ctx, s.subscriptionRepo, s.groupRepo, r.StudentId, r.RoomId)
+	if err != nil {
+		return err
+	}
+
+	archis, err := s.architectures(ctx, r.Area, r.ArchitectureId)
+	if err != nil {
+		return err
+	}
+	rooms, err := s.architectureRooms(ctx, archis[0])
+	if err != nil {
+		return err
+	}
+	if _, ok := rooms[r.RoomId]; !ok {
+		return ROOM_NOT_IN_ARCHITECTURE_ERROR(fmt.Errorf("房间 %s 不在楼栋 %s 中", r.RoomId, r.ArchitectureId))
+	}
+
+	err = s.leaderboardRepo.Save(ctx, &domain.LeaderboardRoom{
+		RoomId:         r.RoomId,
+		RoomName:       roomName,
+		ArchitectureId: r.ArchitectureId,
+		StudentId:      r.StudentId,
+	})
+	if err != nil {
+		return SAVE_LEADERBOARD_ERROR(err)
+	}
+	return nil
+}
+
+func (s *statsService) LeaveLeaderboard(ctx context.Context, r *domain.LeaveLeaderboardRequest) error {
+	// 订阅了房间的任何一位学生都可以退出
+	if _, err := subscribedRoomName(ctx, s.subscriptionRepo, s.groupRepo, r.StudentId, r.RoomId); err != nil {
+		return err
+	}
+	if err := s.leaderboardRepo.Delete(ctx, r.RoomId); err != nil {
+		return SAVE_LEADERBOARD_ERROR(err)
+	}
+	return nil
+}
+
+func (s *statsService) GetLeaderboard(ctx context.Context, r *domain.GetLeaderboardRequest) ([]*domain.LeaderboardEntry, error) {
+	limit := r.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	rooms, err := s.leaderboardRepo.FindByArchitecture(ctx, r.ArchitectureId)
+	if err != nil {
+		return nil, FIND_STATS_ERROR(err)
+	}
+	ids := make([]string, 0, len(rooms))
+	for _, room := range rooms {
+		ids = append(ids, room.RoomId)
+	}
+	usages, _, err := s.roomUsages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*domain.LeaderboardEntry
+	for _, room := range rooms {
+		usage, ok := usages[room.RoomId]
+		if !ok {
+			continue
+		}
+		entries = append(entries, &domain.LeaderboardEntry{
+			RoomId:     room.RoomId,
+			RoomName:   room.RoomName,
+			DailyUsage: roundMoney(usage),
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].DailyUsage < entries[j].DailyUsage
+	})
+	for i, e := range entries {
+		e.Rank = int64(i + 1)
+		if i > 0 && e.DailyUsage == entries[i-1].DailyUsage {
+			e.Rank = entries[i-1].Rank
+		}
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// aggregate 统计房间的日均用电和最近一次读数的余额
+func (s *statsService) aggregate(ctx context.Context, roomIds []string) (*domain.BuildingStats, error) {
+	usages, balances, err := s.roomUsages(ctx, roomIds)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &domain.BuildingStats{
+		Rooms:         int64(len(roomIds)),
+		RoomsWithData: int64(len(balances)),
+		Balances:      make([]domain.BalanceBucket, len(balanceBounds)+1),
+	}
+	for i := range res.Balances {
+		switch {
+		case i == 0:
+			res.Balances[i].Label = fmt.Sprintf("<%g", balanceBounds[0])
+		case i == len(balanceBounds):
+			res.Balances[i].Label = fmt.Sprintf("%g+", balanceBounds[i-1])
+		default:
+			res.Balances[i].Label = fmt.Sprintf("%g-%g", balanceBounds[i-1], balanceBounds[i])
+		}
+	}
+	for _, b := range balances {
+		i := 0
+		for i < len(balanceBounds) && b >= balanceBounds[i] {
+			i++
+		}
+		res.Balances[i].Rooms++
+		if b < s.cfg.NearZero {
+			res.NearZero++
+		}
+	}
+
+	values := make([]float64, 0, len(usages))
+	for _, v := range usages {
+		values = append(values, v)
+	}
+	if len(values) > 0 {
+		sort.Float64s(values)
+		var total float64
+		for _, v := range values {
+			total += v
+		}
+		res.MeanDailyUsage = roundMoney(total / float64(len(values)))
+		mid := len(values) / 2
+		if len(values)%2 == 0 {
+			res.MedianDailyUsage = roundMoney((values[mid-1] + values[mid]) / 2)
+		} else {
+			res.MedianDailyUsage = roundMoney(values[mid])
+		}
+	}
+	return res, nil
+}
+
+// roomUsages 根据最近几天的读数计算每个房间的日均用电量和最近一次读数的余额,没有读数的房间不在结果中
+func (s *statsService) roomUsages(ctx context.Context, roomIds []string) (map[string]float64, map[string]float64, error) {
+	now := time.Now()
+	readings, err := s.readingRepo.FindRangeByRooms(ctx, roomIds, now.AddDate(0, 0, -s.cfg.Days).Unix(), now.Unix()+1)
+	if err != nil {
+		return nil, nil, FIND_STATS_ERROR(err)
+	}
+
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		loc = time.Local
+	}
+	usages := make(map[string]float64, len(readings))
+	balances := make(map[string]float64, len(readings))
+	for roomId, rs := range readings {
+		if len(rs) == 0 {
+			continue
+		}
+		balances[roomId] = rs[len(rs)-1].RemainMoney
+		_, daily := usage.DailyUsages(rs, loc)
+		var total float64
+		for _, v := range daily {
+			total += v
+		}
+		usages[roomId] = total / float64(len(daily))
+	}
+	return usages, balances, nil
+}
+
+// architectures 返回区域中的楼栋,指定了楼栋时只返回该楼栋
+func (s *statsService) architectures(ctx context.Context, area string, architectureId string) ([]domain.Architecture, error) {
+	info, err := s.elecpriceSer.GetArchitecture(ctx, area)
+	if err != nil {
+		return nil, ARCHITECTURE_NOT_FOUND_ERROR(err)
+	}
+	all := info.ArchitectureInfoList.ArchitectureInfo
+	if architectureId == "" {
+		return all, nil
+	}
+	for _, a := range all {
+		if a.ArchitectureID == architectureId {
+			return []domain.Architecture{a}, nil
+		}
+	}
+	return nil, ARCHITECTURE_NOT_FOUND_ERROR(fmt.Errorf("区域 %s 中没有楼栋 %s", area, architectureId))
+}
+
+// architectureRooms 从目录中获取楼栋每一层的房间,key 为房间ID,获取失败的楼层跳过
+func (s *statsService) architectureRooms(ctx context.Context, a domain.Architecture) (map[string]string, error) {
+	begin, err := strconv.Atoi(a.ArchitectureBegin)
+	if err != nil {
+		return nil, ARCHITECTURE_NOT_FOUND_ERROR(fmt.Errorf("楼栋 %s 的起始楼层不合法: %s", a.ArchitectureID, a.ArchitectureBegin))
+	}
+	top, err := strconv.Atoi(a.ArchitectureStorys)
+	if err != nil {
+		return nil, ARCHITECTURE_NOT_FOUND_ERROR(fmt.Errorf("楼栋 %s 的楼层数不合法: %s", a.ArchitectureID, a.ArchitectureStorys))
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		semaphore = make(chan struct{}, 10)
+		res       = make(map[string]string)
+	)
+	for floor := begin; floor <= top; floor++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(floor string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			rooms, err := s.elecpriceSer.GetRoomInfo(ctx, a.ArchitectureID, floor)
+			if err != nil {
+				s.l.Warn("获取楼层房间失败", logger.Error(err),
+					logger.String("architectureID", a.ArchitectureID), logger.String("floor", floor))
+				return
+			}
+			mu.Lock()
+			for id, name := range rooms {
+				res[id] = name
+			}
+			mu.Unlock()
+		}(strconv.Itoa(floor))
+	}
+	wg.Wait()
+	return res, nil
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/pkg/logger"
+	"github.com/asynccnu/be-elecprice/repository"
+	"github.com/spf13/viper"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+var (
+	INVALID_TEMPLATE_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidTemplateError("提醒模板不合法"), "param", err)
+	}
+)
+
+// AlertTemplateData 提醒标题和内容模板中可以使用的变量
+type AlertTemplateData struct {
+	RoomName     string
+	Remain       string // 当前余额
+	Limit        int64  // 触发的阈值
+	Severity     string
+	ForecastDays string // 按最近用电量预计还能用的天数,没有足够读数时为空
+	RecentUsage  string // 最近每天平均用电金额,没有读数时为空
+	// 以下只在用电异常提醒中有值
+	Day          string // 用电异常的日期
+	Usage        string // 当天用电量,单位度
+	AverageUsage string // 平时每天的平均用电量,单位度
+}
+
+// sampleTemplateData 校验和预览模板时使用的示例数据
+var sampleTemplateData = AlertTemplateData{
+	RoomName:     "东区1栋101",
+	Remain:       "12.50",
+	Limit:        20,
+	Severity:     domain.SeverityWarning,
+	ForecastDays: "2.5",
+	RecentUsage:  "5.00",
+	Day:          "2024-01-15",
+	Usage:        "32.50",
+	AverageUsage: "8.20",
+}
+
+// TemplateConfig 提醒模板配置,Severity 为空的模板适用于所有严重程度
+type TemplateConfig struct {
+	DefaultLocale string         `yaml:"defaultLocale"`
+	Items         []TemplateItem `yaml:"items"`
+}
+
+type TemplateItem struct {
+	Severity string `yaml:"severity"`
+	Locale   string `yaml:"locale"`
+	Title    string `yaml:"title"`
+	Content  string `yaml:"content"`
+}
+
+// defaultTemplates 没有配置模板时使用的默认模板
+var defaultTemplates = []TemplateItem{
+	{
+		Locale:  "zh-CN",
+		Title:   "电费不足提醒",
+		Content: "您的房间{{.RoomName}}当前的电费为:{{.Remain}},低于设置阈值,请及时充费",
+	},
+	{
+		Severity: domain.SeverityCritical,
+		Locale:   "zh-CN",
+		Title:    "电费即将耗尽提醒",
+		Content:  "您的房间{{.RoomName}}当前的电费为:{{.Remain}},低于设置阈值,请及时充费",
+	},
+	{
+		Severity: domain.SeverityAnomaly,
+		Locale:   "zh-CN",
+		Title:    "用电异常",
+		Content:  "您的房间{{.RoomName}}在{{.Day}}用电{{.Usage}}度,远高于平时每天的{{.AverageUsage}}度,请检查空调等电器是否忘记关闭",
+	},
+}
+
+type TemplateService interface {
+	// RenderAlert 按学生的语言和提醒的严重程度生成标题和内容,阈值上设置了模板时内容使用阈值的模板
+	RenderAlert(ctx context.Context, msg *domain.ElectricMSG) (title string, content string)
+	// PreviewTemplate 使用示例数据或指定房间的实际数据渲染模板,模板为空时使用已配置的模板
+	PreviewTemplate(ctx context.Context, r *domain.PreviewTemplateRequest) (*domain.PreviewTemplateResponse, error)
+}
+
+type compiledTemplate struct {
+	item    TemplateItem
+	title   *template.Template
+	content *template.Template
+}
+
+type templateService struct {
+	readingRepo      repository.ReadingRepository
+	notificationRepo repository.NotificationRepository
+	defaultLocale    string
+	templates        map[string]compiledTemplate // key 为 severity/locale
+	l                logger.Logger
+}
+
+// NewTemplateService 加载并校验配置中的模板,模板不合法时启动失败
+func NewTemplateService(
+	readingRepo repository.ReadingRepository,
+	notificationRepo repository.NotificationRepository,
+	l logger.Logger,
+) TemplateService {
+	cfg := TemplateConfig{DefaultLocale: "zh-CN"}
+	if err := viper.UnmarshalKey("templates", &cfg); err != nil {
+		panic(err)
+	}
+
+	s := &templateService{
+		readingRepo:      readingRepo,
+		notificationRepo: notificationRepo,
+		defaultLocale:    cfg.DefaultLocale,
+		templates:        make(map[string]compiledTemplate),
+		l:                l,
+	}
+	// 配置中的模板覆盖同样 severity/locale 的默认模板
+	for _, item := range append(defaultTemplates, cfg.Items...) {
+		t, err := compileTemplate(item)
+		if err != nil {
+			panic(fmt.Errorf("提醒模板 %s/%s 不合法: %w", item.Severity, item.Locale, err))
+		}
+		s.templates[templateKey(item.Severity, item.Locale)] = t
+	}
+	return s
+}
+
+func (s *templateService) RenderAlert(ctx context.Context, msg *domain.ElectricMSG) (string, string) {
+	data := s.templateData(ctx, msg.RoomId, *(msg.RoomName), *(msg.Remain), msg.Limit, msg.Severity)
+	if msg.Anomaly != nil {
+		data.Day = msg.Anomaly.Day
+		data.Usage = fmt.Sprintf("%.2f", msg.Anomaly.Usage)
+		data.AverageUsage = fmt.Sprintf("%.2f", msg.Anomaly.Mean)
+	}
+
+	locale := ""
+	if pref, err := s.notificationRepo.FindPreference(ctx, msg.StudentId); err == nil {
+		locale = pref.Locale
+	}
+	t := s.lookup(msg.Severity, locale)
+
+	// 模板在加载时已经用示例数据校验过,出错时退回默认模板
+	fallbackTitle, fallbackContent := fallbackAlert(data)
+	title, err := executeTemplate(t.title, data)
+	if err != nil {
+		s.l.Warn("渲染提醒标题失败", logger.Error(err), logger.String("studentId", msg.StudentId))
+		title = fallbackTitle
+	}
+	content, err := executeTemplate(t.content, data)
+	// 阈值上的模板只用于电费不足提醒
+	if msg.Template != "" && msg.Severity != domain.SeverityAnomaly {
+		content, err = RenderThresholdTemplate(msg.Template, data)
+	}
+	if err != nil {
+		s.l.Warn("渲染提醒内容失败", logger.Error(err), logger.String("studentId", msg.StudentId))
+		content = fallbackContent
+	}
+	return title, content
+}
+
+// fallbackAlert 模板渲染失败时按严重程度使用的标题和内容,和默认模板的文案一致
+func fallbackAlert(data AlertTemplateData) (string, string) {
+	switch data.Severity {
+	case domain.SeverityAnomaly:
+		return "用电异常", fmt.Sprintf("您的房间%s在%s用电%s度,远高于平时每天的%s度,请检查空调等电器是否忘记关闭",
+			data.RoomName, data.Day, data.Usage, data.AverageUsage)
+	case domain.SeverityCritical:
+		return "电费即将耗尽提醒", fmt.Sprintf("您的房间%s当前的电费为:%s,低于设置阈值,请及时充费", data.RoomName, data.Remain)
+	}
+	return "电费不足提醒", fmt.Sprintf("您的房间%s当前的电费为:%s,低于设置阈值,请及时充费", data.RoomName, data.Remain)
+}
+
+func (s *templateService) PreviewTemplate(ctx context.Context, r *domain.PreviewTemplateRequest) (*domain.PreviewTemplateResponse, error) {
+	severity := r.Severity
+	if severity == "" {
+		severity = domain.SeverityWarning
+	}
+	if severityRank(severity) == 0 && severity != domain.SeverityAnomaly {
+		return nil, INVALID_TEMPLATE_ERROR(fmt.Errorf("严重程度不合法: %s", severity))
+	}
+
+	t := s.lookup(severity, r.Locale)
+	if r.Title != "" || r.Content != "" {
+		item := t.item
+		if r.Title != "" {
+			item.Title = r.Title
+		}
+		if r.Content != "" {
+			item.Content = r.Content
+		}
+		var err error
+		t, err = compileTemplate(item)
+		if err != nil {
+			return nil, INVALID_TEMPLATE_ERROR(err)
+		}
+	}
+
+	data := sampleTemplateData
+	data.Severity = severity
+	if r.RoomId != "" {
+		// 使用房间最近的读数,便于检查预计天数等变量的实际效果,没有读数时余额使用示例数据
+		remain := sampleTemplateData.Remain
+		if reading, err := s.readingRepo.FindLatest(ctx, r.RoomId); err == nil {
+			remain = strconv.FormatFloat(reading.RemainMoney, 'f', 2, 64)
+		}
+		data = s.templateData(ctx, r.RoomId, r.RoomId, remain, sampleTemplateData.Limit, severity)
+		// 用电异常的变量仍然使用示例数据
+		data.Day, data.Usage, data.AverageUsage = sampleTemplateData.Day, sampleTemplateData.Usage, sampleTemplateData.AverageUsage
+	}
+
+	title, err := executeTemplate(t.title, data)
+	if err != nil {
+		return nil, INVALID_TEMPLATE_ERROR(err)
+	}
+	content, err := executeTemplate(t.content, data)
+	if err != nil {
+		return nil, INVALID_TEMPLATE_ERROR(err)
+	}
+	return &domain.PreviewTemplateResponse{Title: title, Content: content}, nil
+}
+
+// lookup 依次查找 severity/locale、severity/默认语言、所有严重程度/locale、所有严重程度/默认语言 的模板
+func (s *templateService) lookup(severity string, locale string) compiledTemplate {
+	for _, key := range []string{
+		templateKey(severity, locale),
+		templateKey(severity, s.defaultLocale),
+		templateKey("", locale),
+		templateKey("", s.defaultLocale),
+	} {
+		if t, ok := s.templates[key]; ok {
+			return t
+		}
+	}
+	return s.templates[templateKey("", defaultTemplates[0].Locale)]
+}
+
+// templateData 根据最近 7 天的读数计算平均用电金额和预计可用天数
+func (s *templateService) templateData(ctx context.Context, roomId string, roomName string, remain string, limit int64, severity string) AlertTemplateData {
+	data := AlertTemplateData{
+		RoomName: roomName,
+		Remain:   remain,
+		Limit:    limit,
+		Severity: severity,
+	}
+
+	now := time.Now()
+	readings, err := s.readingRepo.FindRange(ctx, roomId, now.AddDate(0, 0, -7).Unix(), now.Unix()+1)
+	if err != nil || len(readings) == 0 {
+		return data
+	}
+	var total float64
+	for _, r := range readings {
+		total += r.YesterdayUseMoney
+	}
+	avg := total / float64(len(readings))
+	data.RecentUsage = fmt.Sprintf("%.2f", avg)
+
+	remainMoney, err := strconv.ParseFloat(remain, 64)
+	if err == nil && avg > 0 && remainMoney > 0 {
+		data.ForecastDays = fmt.Sprintf("%.1f", remainMoney/avg)
+	}
+	return data
+}
+
+// compileTemplate 解析模板并使用示例数据执行一次,提前发现引用了不存在的变量等错误
+func compileTemplate(item TemplateItem) (compiledTemplate, error) {
+	var (
+		t   = compiledTemplate{item: item}
+		err error
+	)
+	t.title, err = template.New("title").Option("missingkey=error").Parse(item.Title)
+	if err != nil {
+		return t, err
+	}
+	t.content, err = template.New("content").Option("missingkey=error").Parse(item.Content)
+	if err != nil {
+		return t, err
+	}
+	if _, err := executeTemplate(t.title, sampleTemplateData); err != nil {
+		return t, err
+	}
+	if _, err := executeTemplate(t.content, sampleTemplateData); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+func executeTemplate(t *template.Template, data AlertTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func templateKey(severity string, locale string) string {
+	return severity + "/" + locale
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/asynccnu/be-elecprice/domain"
+	"text/template"
+)
+
+// RenderThresholdTemplate 使用阈值上设置的模板生成提醒内容
+func RenderThresholdTemplate(tpl string, data AlertTemplateData) (string, error) {
+	t, err := template.New("threshold").Option("missingkey=error").Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+	return executeTemplate(t, data)
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case domain.SeverityInfo:
+		return 1
+	case domain.SeverityWarning:
+		return 2
+	case domain.SeverityCritical:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// validateThresholds 检查阈值的严重程度和模板,严重程度为空时默认为 warning
+func validateThresholds(thresholds []*domain.Threshold) error {
+	for i, t := range thresholds {
+		if t.Severity == "" {
+			t.Severity = domain.SeverityWarning
+		}
+		if severityRank(t.Severity) == 0 {
+			return fmt.Errorf("第%d个阈值的严重程度不合法: %s", i+1, t.Severity)
+		}
+		if t.Cooldown < 0 {
+			return errors.New("冷却时间不能为负数")
+		}
+		if t.Template != "" {
+			_, err := RenderThresholdTemplate(t.Template, sampleTemplateData)
+			if err != nil {
+				return fmt.Errorf("第%d个阈值的模板不合法: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pickThreshold 返回余额越过的阈值中最严重的一个,同样严重时取金额最低的,
+// 该阈值仍在冷却中时不提醒,也不会退而提醒较轻的阈值
+func pickThreshold(sub *domain.Subscription, remain float64, now int64) *domain.Threshold {
+	thresholds := sub.Thresholds
+	if len(thresholds) == 0 {
+		// 旧版单阈值订阅,每次检查都提醒
+		thresholds = []*domain.Threshold{{Limit: sub.Limit, Severity: domain.SeverityWarning}}
+	}
+
+	var picked *domain.Threshold
+	for _, t := range thresholds {
+		if remain >= float64(t.Limit) {
+			continue
+		}
+		if picked == nil {
+			picked = t
+			continue
+		}
+		rank, pickedRank := severityRank(t.Severity), severityRank(picked.Severity)
+		if rank > pickedRank || (rank == pickedRank && t.Limit < picked.Limit) {
+			picked = t
+		}
+	}
+
+	if picked == nil {
+		return nil
+	}
+	if picked.Cooldown > 0 && now-picked.LastAlertAt < picked.Cooldown {
+		return nil
+	}
+	return picked
+}
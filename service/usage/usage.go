@@ -0,0 +1,88 @@
+// Package usage 把电费读数整理为每天的用电并检测用电异常,只依赖 domain
+package usage
+
+import (
+	"github.com/asynccnu/be-elecprice/domain"
+	"math"
+	"time"
+)
+
+// AnomalyConfig 用电异常检测的参数,把最后一天的用电量和之前 Window 天比较
+type AnomalyConfig struct {
+	Window      int     `yaml:"window"`      // 作为基线的天数
+	MinDays     int     `yaml:"minDays"`     // 基线中至少要有这么多天的数据才检测
+	ZScore      float64 `yaml:"zScore"`      // 订阅没有设置时使用的标准分数
+	MinIncrease float64 `yaml:"minIncrease"` // 比平均值至少多用这么多度才算异常,避免用电很少的房间误报
+}
+
+// DefaultAnomalyConfig 没有配置时使用的参数
+var DefaultAnomalyConfig = AnomalyConfig{
+	Window:      14,
+	MinDays:     7,
+	ZScore:      3,
+	MinIncrease: 3,
+}
+
+// ScoreAnomaly 计算 usages 最后一天相对之前 cfg.Window 天的标准分数,usages 按日期升序
+// 数据不足或者增加的用电量不到 cfg.MinIncrease 时返回 false
+func ScoreAnomaly(usages []float64, cfg AnomalyConfig) (mean float64, std float64, z float64, ok bool) {
+	if len(usages) < 2 {
+		return 0, 0, 0, false
+	}
+	last := usages[len(usages)-1]
+	baseline := usages[:len(usages)-1]
+	if len(baseline) > cfg.Window {
+		baseline = baseline[len(baseline)-cfg.Window:]
+	}
+	if len(baseline) < cfg.MinDays {
+		return 0, 0, 0, false
+	}
+
+	for _, v := range baseline {
+		mean += v
+	}
+	mean /= float64(len(baseline))
+	for _, v := range baseline {
+		std += (v - mean) * (v - mean)
+	}
+	std = math.Sqrt(std / float64(len(baseline)))
+	// 用电非常稳定时标准差接近 0,设置下限避免一点波动就被判为异常
+	std = math.Max(std, math.Max(mean*0.1, 0.1))
+
+	if last-mean < cfg.MinIncrease {
+		return mean, std, 0, false
+	}
+	return mean, std, (last - mean) / std, true
+}
+
+// DailyRecords 把按时间升序的读数整理为每天的用电记录,统计、导出和异常检测都以此划分日期
+// 读数中的昨日用电属于读取时间的前一天,同一天有多次读数时取最后一次
+func DailyRecords(readings []*domain.Reading, loc *time.Location) []*domain.UsageRecord {
+	var res []*domain.UsageRecord
+	for _, r := range readings {
+		record := &domain.UsageRecord{
+			Date:    time.Unix(r.ReadAt, 0).In(loc).AddDate(0, 0, -1).Format(time.DateOnly),
+			Value:   r.YesterdayUseValue,
+			Money:   r.YesterdayUseMoney,
+			Balance: r.RemainMoney,
+		}
+		if n := len(res); n > 0 && res[n-1].Date == record.Date {
+			res[n-1] = record
+			continue
+		}
+		res = append(res, record)
+	}
+	return res
+}
+
+// DailyUsages 返回每天的日期和用电量,见 DailyRecords
+func DailyUsages(readings []*domain.Reading, loc *time.Location) ([]string, []float64) {
+	records := DailyRecords(readings, loc)
+	days := make([]string, 0, len(records))
+	usages := make([]float64, 0, len(records))
+	for _, r := range records {
+		days = append(days, r.Date)
+		usages = append(usages, r.Value)
+	}
+	return days, usages
+}
@@ -0,0 +1,178 @@
+package usage
+
+import (
+	"github.com/asynccnu/be-elecprice/domain"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScoreAnomaly(t *testing.T) {
+	cfg := AnomalyConfig{Window: 7, MinDays: 5, ZScore: 3, MinIncrease: 3}
+	testCases := []struct {
+		name     string
+		usages   []float64
+		wantOk   bool
+		wantMean float64
+		wantStd  float64
+		wantZ    float64
+	}{
+		{
+			name:     "平稳的用电",
+			usages:   []float64{10, 11, 9, 10, 11, 9, 10, 11},
+			wantOk:   false,
+			wantMean: 10,
+			wantStd:  1,
+		},
+		{
+			name:     "单日突增",
+			usages:   []float64{10, 11, 9, 10, 11, 9, 10, 20},
+			wantOk:   true,
+			wantMean: 10,
+			// 标准差 sqrt(4/7) 低于平均值的 10%,使用下限 1
+			wantStd: 1,
+			wantZ:   10,
+		},
+		{
+			name:   "没有数据",
+			wantOk: false,
+		},
+		{
+			name:   "只有一天",
+			usages: []float64{20},
+			wantOk: false,
+		},
+		{
+			name:   "基线天数不足",
+			usages: []float64{10, 10, 10, 10, 20},
+			wantOk: false,
+		},
+		{
+			name:     "基线没有波动",
+			usages:   []float64{5, 5, 5, 5, 5, 5, 5, 9},
+			wantOk:   true,
+			wantMean: 5,
+			wantStd:  0.5,
+			wantZ:    8,
+		},
+		{
+			name:     "基线没有用电",
+			usages:   []float64{0, 0, 0, 0, 0, 0, 0, 3},
+			wantOk:   true,
+			wantMean: 0,
+			wantStd:  0.1,
+			wantZ:    30,
+		},
+		{
+			name:     "增加的用电量不足",
+			usages:   []float64{1, 1, 1, 1, 1, 1, 1, 3.5},
+			wantOk:   false,
+			wantMean: 1,
+			wantStd:  0.1,
+		},
+		{
+			name: "只使用最近 Window 天作为基线",
+			usages: []float64{
+				100, 100, 100,
+				10, 10, 10, 10, 10, 10, 10,
+				20,
+			},
+			wantOk:   true,
+			wantMean: 10,
+			wantStd:  1,
+			wantZ:    10,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mean, std, z, ok := ScoreAnomaly(tc.usages, cfg)
+			if ok != tc.wantOk {
+				t.Fatalf("ok = %v, 期望 %v", ok, tc.wantOk)
+			}
+			if !almostEqual(mean, tc.wantMean) || !almostEqual(std, tc.wantStd) || !almostEqual(z, tc.wantZ) {
+				t.Errorf("mean, std, z = %v, %v, %v, 期望 %v, %v, %v",
+					mean, std, z, tc.wantMean, tc.wantStd, tc.wantZ)
+			}
+		})
+	}
+}
+
+func TestDailyRecords(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	at := func(day, hour int) int64 {
+		return time.Date(2024, 3, day, hour, 0, 0, 0, loc).Unix()
+	}
+	reading := func(readAt int64, value float64) *domain.Reading {
+		return &domain.Reading{ReadAt: readAt, YesterdayUseValue: value, YesterdayUseMoney: value / 2, RemainMoney: 100 - value}
+	}
+
+	testCases := []struct {
+		name     string
+		readings []*domain.Reading
+		want     []domain.UsageRecord
+	}{
+		{
+			name: "没有读数",
+		},
+		{
+			name:     "昨日用电属于前一天",
+			readings: []*domain.Reading{reading(at(2, 8), 6)},
+			want:     []domain.UsageRecord{{Date: "2024-03-01", Value: 6, Money: 3, Balance: 94}},
+		},
+		{
+			name:     "同一天取最后一次读数",
+			readings: []*domain.Reading{reading(at(2, 8), 6), reading(at(2, 20), 7), reading(at(3, 8), 4)},
+			want: []domain.UsageRecord{
+				{Date: "2024-03-01", Value: 7, Money: 3.5, Balance: 93},
+				{Date: "2024-03-02", Value: 4, Money: 2, Balance: 96},
+			},
+		},
+		{
+			name: "按 loc 划分日期",
+			// 当地时间 00:30 在 UTC 中还是前一天
+			readings: []*domain.Reading{reading(at(2, 20), 7), reading(time.Date(2024, 3, 3, 0, 30, 0, 0, loc).Unix(), 4)},
+			want: []domain.UsageRecord{
+				{Date: "2024-03-01", Value: 7, Money: 3.5, Balance: 93},
+				{Date: "2024-03-02", Value: 4, Money: 2, Balance: 96},
+			},
+		},
+		{
+			name:     "缺少的日期不补齐",
+			readings: []*domain.Reading{reading(at(2, 8), 6), reading(at(5, 8), 4)},
+			want: []domain.UsageRecord{
+				{Date: "2024-03-01", Value: 6, Money: 3, Balance: 94},
+				{Date: "2024-03-04", Value: 4, Money: 2, Balance: 96},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			records := DailyRecords(tc.readings, loc)
+			if len(records) != len(tc.want) {
+				t.Fatalf("DailyRecords 返回 %d 天, 期望 %d", len(records), len(tc.want))
+			}
+			for i, r := range records {
+				if *r != tc.want[i] {
+					t.Errorf("第 %d 天 = %+v, 期望 %+v", i, *r, tc.want[i])
+				}
+			}
+
+			// DailyUsages 和 DailyRecords 使用相同的日期划分
+			days, usages := DailyUsages(tc.readings, loc)
+			if len(days) != len(tc.want) || len(usages) != len(tc.want) {
+				t.Fatalf("DailyUsages 返回 %d 天 %d 个用电量, 期望 %d", len(days), len(usages), len(tc.want))
+			}
+			for i := range days {
+				if days[i] != tc.want[i].Date || usages[i] != tc.want[i].Value {
+					t.Errorf("DailyUsages 第 %d 天 = %s %v, 期望 %s %v", i, days[i], usages[i], tc.want[i].Date, tc.want[i].Value)
+				}
+			}
+		})
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	elecpricev1 "github.com/asynccnu/be-api/gen/proto/elecprice/v1"
+	"github.com/asynccnu/be-elecprice/domain"
+	"github.com/asynccnu/be-elecprice/pkg/errorx"
+	"github.com/asynccnu/be-elecprice/repository"
+	"github.com/spf13/viper"
+	"sync"
+	"time"
+)
+
+var (
+	INVALID_WATCH_ERROR = func(err error) error {
+		return errorx.New(elecpricev1.ErrorInvalidWatchError("订阅实时电费的参数不合法"), "param", err)
+	}
+)
+
+// WatchConfig 实时电费推送的参数
+type WatchConfig struct {
+	Heartbeat int `yaml:"heartbeat"` // 没有新读数时发送心跳的间隔,单位秒
+	MaxRooms  int `yaml:"maxRooms"`  // 一次最多订阅的房间数
+}
+
+// WatchService 向客户端推送房间的新读数,代替轮询 GetPrice
+type WatchService interface {
+	// WatchPrice 先推送每个房间最近一次的读数,之后每保存一次新读数就推送一次,ctx 结束时返回 nil
+	WatchPrice(ctx context.Context, r *domain.WatchPriceRequest, emit func(e *domain.WatchPriceEvent) error) error
+	// Close 结束所有推送中的连接,优雅关闭 grpc 服务时会等待所有连接结束,必须先调用
+	Close()
+}
+
+type watchService struct {
+	subscriptionRepo repository.SubscriptionRepository
+	groupRepo        repository.RoomGroupRepository
+	readingRepo      repository.ReadingRepository
+	cfg              WatchConfig
+	done             chan struct{}
+	closeOnce        sync.Once
+}
+
+func NewWatchService(
+	subscriptionRepo repository.SubscriptionRepository,
+	groupRepo repository.RoomGroupRepository,
+	readingRepo repository.ReadingRepository,
+) WatchService {
+	cfg := WatchConfig{Heartbeat: 30, MaxRooms: 10}
+	if err := viper.UnmarshalKey("watch", &cfg); err != nil {
+		panic(err)
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 30
+	}
+	return &watchService{subscriptionRepo: subscriptionRepo, groupRepo: groupRepo, readingRepo: readingRepo, cfg: cfg, done: make(chan struct{})}
+}
+
+func (s *watchService) WatchPrice(ctx context.Context, r *domain.WatchPriceRequest, emit func(e *domain.WatchPriceEvent) error) error {
+	roomIds := make([]string, 0, len(r.RoomIds))
+	seen := make(map[string]bool, len(r.RoomIds))
+	for _, roomId := range r.RoomIds {
+		if roomId == "" || seen[roomId] {
+			continue
+		}
+		seen[roomId] = true
+		roomIds = append(roomIds, roomId)
+	}
+	if len(roomIds) == 0 {
+		return INVALID_WATCH_ERROR(fmt.Errorf("没有指定房间"))
+	}
+	if len(roomIds) > s.cfg.MaxRooms {
+		return INVALID_WATCH_ERROR(fmt.Errorf("一次最多订阅 %d 个房间", s.cfg.MaxRooms))
+	}
+	for _, roomId := range roomIds {
+		if _, err := subscribedRoomName(ctx, s.subscriptionRepo, s.groupRepo, r.StudentId, roomId); err != nil {
+			return err
+		}
+	}
+
+	// 先订阅再读取最近的读数,避免错过两者之间保存的读数
+	readings, cancel := s.readingRepo.Subscribe(roomIds)
+	defer cancel()
+
+	for _, roomId := range roomIds {
+		// 还没有读数的房间等第一次读数时再推送
+		reading, err := s.readingRepo.FindLatest(ctx, roomId)
+		if err != nil {
+			continue
+		}
+		if err := emit(&domain.WatchPriceEvent{Reading: reading}); err != nil {
+			return err
+		}
+	}
+
+	heartbeat := time.NewTicker(time.Duration(s.cfg.Heartbeat) * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.done:
+			return nil
+		case reading := <-readings:
+			if err := emit(&domain.WatchPriceEvent{Reading: reading}); err != nil {
+				return err
+			}
+			heartbeat.Reset(time.Duration(s.cfg.Heartbeat) * time.Second)
+		case <-heartbeat.C:
+			if err := emit(&domain.WatchPriceEvent{Heartbeat: true}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *watchService) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
@@ -7,7 +7,10 @@ import (
 	"github.com/asynccnu/be-elecprice/cron"
 	"github.com/asynccnu/be-elecprice/grpc"
 	"github.com/asynccnu/be-elecprice/ioc"
+	"github.com/asynccnu/be-elecprice/repository"
+	"github.com/asynccnu/be-elecprice/repository/cache"
 	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/migration"
 	"github.com/asynccnu/be-elecprice/service"
 	"github.com/google/wire"
 )
@@ -16,16 +19,66 @@ func InitApp() App {
 	wire.Build(
 		grpc.NewElecpriceGrpcService,
 		service.NewElecpriceService,
+		service.NewJobService,
+		service.NewRetentionService,
+		service.NewPrefixYearRule,
+		service.NewNotificationService,
+		service.NewAlertHistoryService,
+		service.NewTemplateService,
+		service.NewRoomGroupService,
+		service.NewSettlementService,
+		service.NewReportService,
+		service.NewStatsService,
+		service.NewExportService,
+		service.NewWatchService,
+		service.NewCarbonEstimator,
 		dao.NewElecpriceDAO,
+		dao.NewJobRunDAO,
+		dao.NewReadingDAO,
+		dao.NewNotificationDAO,
+		dao.NewSnoozeDAO,
+		dao.NewAlertHistoryDAO,
+		dao.NewRoomGroupDAO,
+		dao.NewRechargeDAO,
+		dao.NewMonthlyUsageDAO,
+		dao.NewLeaderboardDAO,
+		cache.NewRedisSubscriptionCache,
+		cache.NewRedisCatalogCache,
+		cache.NewRedisReadingBroker,
+		repository.NewCachedSubscriptionRepository,
+		repository.NewReadingRepository,
+		repository.NewCatalogRepository,
+		repository.NewNotificationRepository,
+		repository.NewSnoozeRepository,
+		repository.NewAlertHistoryRepository,
+		repository.NewRoomGroupRepository,
+		repository.NewRechargeRepository,
+		repository.NewMonthlyUsageRepository,
+		repository.NewLeaderboardRepository,
 		// 第三方
 		ioc.InitEtcdClient,
 		ioc.InitDB,
+		ioc.InitRedis,
 		ioc.InitLogger,
 		ioc.InitGRPCxKratosServer,
 		ioc.InitFeedClient,
+		ioc.InitNotifier,
 		cron.NewElecpriceController,
+		cron.NewRetentionController,
+		cron.NewDeliveryController,
+		cron.NewReportController,
+		cron.NewSamplerController,
 		cron.NewCron,
 		NewApp,
 	)
 	return App{}
 }
+
+func InitMigrator() *migration.Migrator {
+	wire.Build(
+		migration.NewMigrator,
+		ioc.InitDB,
+		ioc.InitLogger,
+	)
+	return &migration.Migrator{}
+}
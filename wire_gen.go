@@ -10,7 +10,10 @@ import (
 	"github.com/asynccnu/be-elecprice/cron"
 	"github.com/asynccnu/be-elecprice/grpc"
 	"github.com/asynccnu/be-elecprice/ioc"
+	"github.com/asynccnu/be-elecprice/repository"
+	"github.com/asynccnu/be-elecprice/repository/cache"
 	"github.com/asynccnu/be-elecprice/repository/dao"
+	"github.com/asynccnu/be-elecprice/repository/migration"
 	"github.com/asynccnu/be-elecprice/service"
 )
 
@@ -20,13 +23,61 @@ func InitApp() App {
 	logger := ioc.InitLogger()
 	db := ioc.InitDB(logger)
 	elecpriceDAO := dao.NewElecpriceDAO(db)
-	elecpriceService := service.NewElecpriceService(elecpriceDAO, logger)
-	elecpriceServiceServer := grpc.NewElecpriceGrpcService(elecpriceService)
+	cmdable := ioc.InitRedis()
+	subscriptionCache := cache.NewRedisSubscriptionCache(cmdable)
+	subscriptionRepository := repository.NewCachedSubscriptionRepository(elecpriceDAO, subscriptionCache, logger)
+	readingDAO := dao.NewReadingDAO(db)
+	readingBroker := cache.NewRedisReadingBroker(cmdable)
+	readingRepository := repository.NewReadingRepository(readingDAO, readingBroker)
+	catalogCache := cache.NewRedisCatalogCache(cmdable)
+	catalogRepository := repository.NewCatalogRepository(catalogCache)
+	snoozeDAO := dao.NewSnoozeDAO(db)
+	snoozeRepository := repository.NewSnoozeRepository(snoozeDAO)
+	roomGroupDAO := dao.NewRoomGroupDAO(db)
+	roomGroupRepository := repository.NewRoomGroupRepository(roomGroupDAO)
+	rechargeDAO := dao.NewRechargeDAO(db)
+	rechargeRepository := repository.NewRechargeRepository(rechargeDAO)
+	carbonEstimator := service.NewCarbonEstimator()
+	elecpriceService := service.NewElecpriceService(subscriptionRepository, readingRepository, catalogRepository, snoozeRepository, roomGroupRepository, rechargeRepository, carbonEstimator, logger)
+	jobRunDAO := dao.NewJobRunDAO(db)
+	jobService := service.NewJobService(jobRunDAO, logger)
+	notificationDAO := dao.NewNotificationDAO(db)
+	notificationRepository := repository.NewNotificationRepository(notificationDAO)
 	client := ioc.InitEtcdClient()
-	server := ioc.InitGRPCxKratosServer(elecpriceServiceServer, client, logger)
 	feedServiceClient := ioc.InitFeedClient(client)
-	elecpriceController := cron.NewElecpriceController(feedServiceClient, elecpriceService, logger)
-	v := cron.NewCron(elecpriceController)
-	app := NewApp(server, v)
+	dispatcher := ioc.InitNotifier(feedServiceClient)
+	notificationService := service.NewNotificationService(notificationRepository, dispatcher, logger)
+	alertHistoryDAO := dao.NewAlertHistoryDAO(db)
+	alertHistoryRepository := repository.NewAlertHistoryRepository(alertHistoryDAO)
+	alertHistoryService := service.NewAlertHistoryService(alertHistoryRepository)
+	templateService := service.NewTemplateService(readingRepository, notificationRepository, logger)
+	roomGroupService := service.NewRoomGroupService(roomGroupRepository, logger)
+	settlementService := service.NewSettlementService(roomGroupRepository, readingRepository, rechargeRepository)
+	monthlyUsageDAO := dao.NewMonthlyUsageDAO(db)
+	monthlyUsageRepository := repository.NewMonthlyUsageRepository(monthlyUsageDAO)
+	reportService := service.NewReportService(subscriptionRepository, roomGroupRepository, monthlyUsageRepository, catalogRepository, carbonEstimator, logger)
+	leaderboardDAO := dao.NewLeaderboardDAO(db)
+	leaderboardRepository := repository.NewLeaderboardRepository(leaderboardDAO)
+	statsService := service.NewStatsService(elecpriceService, subscriptionRepository, roomGroupRepository, readingRepository, leaderboardRepository, logger)
+	exportService := service.NewExportService(subscriptionRepository, roomGroupRepository, readingRepository)
+	watchService := service.NewWatchService(subscriptionRepository, roomGroupRepository, readingRepository)
+	elecpriceServiceServer := grpc.NewElecpriceGrpcService(elecpriceService, jobService, notificationService, alertHistoryService, templateService, roomGroupService, settlementService, reportService, statsService, exportService, watchService)
+	server := ioc.InitGRPCxKratosServer(elecpriceServiceServer, client, logger)
+	elecpriceController := cron.NewElecpriceController(elecpriceService, notificationService, alertHistoryService, templateService, jobService, logger)
+	enrollmentYearRule := service.NewPrefixYearRule()
+	retentionService := service.NewRetentionService(subscriptionRepository, readingRepository, enrollmentYearRule, logger)
+	retentionController := cron.NewRetentionController(retentionService, jobService, logger)
+	deliveryController := cron.NewDeliveryController(notificationService, alertHistoryService, jobService, logger)
+	reportController := cron.NewReportController(reportService, notificationService, jobService, logger)
+	samplerController := cron.NewSamplerController(elecpriceService, jobService, logger)
+	v := cron.NewCron(elecpriceController, retentionController, deliveryController, reportController, samplerController)
+	app := NewApp(server, v, jobService, watchService, client, db, cmdable, logger)
 	return app
 }
+
+func InitMigrator() *migration.Migrator {
+	logger := ioc.InitLogger()
+	db := ioc.InitDB(logger)
+	migrator := migration.NewMigrator(db, logger)
+	return migrator
+}